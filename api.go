@@ -0,0 +1,225 @@
+package bililive
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultAPIBaseURL = "https://api.live.bilibili.com"
+	defaultUserAgent  = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/96.0.4664.110 Safari/537.36"
+)
+
+// 接口错误
+var (
+	ErrNoCredential    = errors.New("未设置登录凭据")
+	ErrNotLoggedIn     = errors.New("账号未登录或登录已过期")
+	ErrCSRF            = errors.New("csrf校验失败")
+	ErrRateLimited     = errors.New("发送频率过快")
+	ErrMessageFiltered = errors.New("弹幕被屏蔽")
+	ErrMessageRepeat   = errors.New("重复的弹幕")
+	ErrMuted           = errors.New("已被禁言")
+)
+
+// APIError 接口返回的错误，可以使用errors.Is判断错误类型，如errors.Is(err, ErrRateLimited)
+type APIError struct {
+	Code    int    // 错误码
+	Message string // 错误信息
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("接口错误 %d: %s", e.Code, e.Message)
+}
+
+// Unwrap 按错误码和错误信息归类
+func (e *APIError) Unwrap() error {
+	switch {
+	case e.Code == -101:
+		return ErrNotLoggedIn
+	case e.Code == -111:
+		return ErrCSRF
+	case e.Code == -403 || strings.Contains(e.Message, "无权限") || strings.Contains(e.Message, "没有权限"):
+		return ErrPermissionDenied
+	case e.Code == 10030 || e.Code == 10031 || strings.Contains(e.Message, "频率过快") || e.Message == "msg in 1s":
+		return ErrRateLimited
+	case e.Message == "f" || e.Message == "k":
+		return ErrMessageFiltered
+	case e.Message == "msg repeat" || strings.Contains(e.Message, "重复"):
+		return ErrMessageRepeat
+	case strings.Contains(e.Message, "禁言"):
+		return ErrMuted
+	}
+	return nil
+}
+
+// Credential 登录凭据，可以从浏览器Cookie中获取
+type Credential struct {
+	SESSDATA   string // Cookie中的SESSDATA
+	BiliJct    string // Cookie中的bili_jct，即csrf token
+	DedeUserID int64  // Cookie中的DedeUserID，即登录用户ID
+	Buvid3     string // Cookie中的buvid3，可选
+}
+
+// ParseCredential 从浏览器Cookie字符串解析登录凭据
+func ParseCredential(cookie string) (*Credential, error) {
+	c := &Credential{}
+	for _, part := range strings.Split(cookie, ";") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "SESSDATA":
+			c.SESSDATA = kv[1]
+		case "bili_jct":
+			c.BiliJct = kv[1]
+		case "DedeUserID":
+			c.DedeUserID, _ = strconv.ParseInt(kv[1], 10, 64)
+		case "buvid3":
+			c.Buvid3 = kv[1]
+		}
+	}
+	if c.SESSDATA == "" || c.BiliJct == "" {
+		return nil, errors.New("Cookie中缺少SESSDATA或bili_jct")
+	}
+	return c, nil
+}
+
+// Cookie 请求使用的Cookie
+func (c *Credential) Cookie() string {
+	cookie := fmt.Sprintf("SESSDATA=%s; bili_jct=%s", c.SESSDATA, c.BiliJct)
+	if c.DedeUserID > 0 {
+		cookie += fmt.Sprintf("; DedeUserID=%d", c.DedeUserID)
+	}
+	if c.Buvid3 != "" {
+		cookie += "; buvid3=" + c.Buvid3
+	}
+	return cookie
+}
+
+// 接口返回
+type apiResult struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Msg     string          `json:"msg"`
+	Data    json.RawMessage `json:"data"`
+}
+
+func (live *Live) httpClient() *http.Client {
+	if live.HTTPClient != nil {
+		return live.HTTPClient
+	}
+	return defaultHTTPClient
+}
+
+func (live *Live) apiURL(path string) string {
+	base := live.APIBaseURL
+	if base == "" {
+		base = defaultAPIBaseURL
+	}
+	return strings.TrimRight(base, "/") + path
+}
+
+// GET请求接口，设置了登录凭据时带上Cookie，data为nil时不解析返回数据
+func (live *Live) apiGet(ctx context.Context, path string, query url.Values, data interface{}) (*apiResult, error) {
+	u := live.apiURL(path)
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	return live.apiDo(ctx, req, data)
+}
+
+// POST表单请求接口，需要登录凭据，自动填充csrf
+func (live *Live) apiPost(ctx context.Context, path string, form url.Values, data interface{}) (*apiResult, error) {
+	if live.Credential == nil {
+		return nil, ErrNoCredential
+	}
+	if form == nil {
+		form = url.Values{}
+	}
+	form.Set("csrf", live.Credential.BiliJct)
+	form.Set("csrf_token", live.Credential.BiliJct)
+	req, err := http.NewRequest(http.MethodPost, live.apiURL(path), strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return live.apiDo(ctx, req, data)
+}
+
+func (live *Live) apiDo(ctx context.Context, req *http.Request, data interface{}) (*apiResult, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("User-Agent", defaultUserAgent)
+	req.Header.Set("Origin", "https://live.bilibili.com")
+	req.Header.Set("Referer", "https://live.bilibili.com/")
+	if live.Credential != nil {
+		req.Header.Set("Cookie", live.Credential.Cookie())
+	}
+
+	resp, err := live.httpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, &APIError{Code: resp.StatusCode, Message: http.StatusText(resp.StatusCode)}
+	}
+
+	result := &apiResult{}
+	if err := json.Unmarshal(body, result); err != nil {
+		return nil, fmt.Errorf("解析接口返回失败: %w", err)
+	}
+	if result.Message == "" {
+		result.Message = result.Msg
+	}
+	if result.Code != 0 {
+		return result, &APIError{Code: result.Code, Message: result.Message}
+	}
+	if data == nil || len(result.Data) == 0 || string(result.Data) == "null" {
+		return result, nil
+	}
+	if err := json.Unmarshal(result.Data, data); err != nil {
+		return result, fmt.Errorf("解析接口数据失败: %w", err)
+	}
+	return result, nil
+}
+
+// 获取真实房间ID
+func (live *Live) realRoomID(ctx context.Context, roomID int) (int, error) {
+	room, err := live.resolveRoom(ctx, roomID)
+	if err != nil {
+		return 0, err
+	}
+	return room.RealRoomID, nil
+}
+
+// 等待一段时间，ctx结束时返回错误
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,54 @@
+package bililive
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// 本地接口服务，room_init固定返回真实房间1000，其他接口由mux注册
+func newTestLive(t *testing.T, mux *http.ServeMux) (*Live, *httptest.Server) {
+	t.Helper()
+	mux.HandleFunc(roomInitPath, func(w http.ResponseWriter, r *http.Request) {
+		writeTestAPI(w, 0, "", &roomInfoData{RoomID: 1000, ShortID: 1, LiveStatus: LiveStatusLive})
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	live := &Live{
+		APIBaseURL: srv.URL,
+		HTTPClient: srv.Client(),
+		Credential: &Credential{SESSDATA: "sess", BiliJct: "csrf", DedeUserID: 1},
+	}
+	return live, srv
+}
+
+func writeTestAPI(w http.ResponseWriter, code int, message string, data interface{}) {
+	raw, _ := json.Marshal(data)
+	_ = json.NewEncoder(w).Encode(&apiResult{Code: code, Message: message, Data: raw})
+}
+
+func TestAPIErrorUnwrap(t *testing.T) {
+	cases := []struct {
+		err  *APIError
+		want error
+	}{
+		{&APIError{Code: -101, Message: "账号未登录"}, ErrNotLoggedIn},
+		{&APIError{Code: -111, Message: "csrf 校验失败"}, ErrCSRF},
+		{&APIError{Code: 10030, Message: "您发送弹幕的频率过快"}, ErrRateLimited},
+		{&APIError{Code: 0, Message: "msg in 1s"}, ErrRateLimited},
+		{&APIError{Code: 0, Message: "msg repeat"}, ErrMessageRepeat},
+		{&APIError{Code: 0, Message: "f"}, ErrMessageFiltered},
+		{&APIError{Code: 0, Message: "k"}, ErrMessageFiltered},
+		{&APIError{Code: 1003, Message: "你被禁言啦"}, ErrMuted},
+	}
+	for _, c := range cases {
+		if !errors.Is(c.err, c.want) {
+			t.Errorf("%v is not %v", c.err, c.want)
+		}
+	}
+	if (&APIError{Code: 0, Message: "ok"}).Unwrap() != nil {
+		t.Error("ok should not be an error")
+	}
+}
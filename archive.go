@@ -0,0 +1,223 @@
+package bililive
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 弹幕文件头，与b站视频弹幕相同
+const danmakuXMLHeader = `<?xml version="1.0" encoding="UTF-8"?>
+<i>
+<chatserver>chat.bilibili.com</chatserver>
+<chatid>0</chatid>
+<mission>0</mission>
+<maxlimit>1000</maxlimit>
+<state>0</state>
+<real_name>0</real_name>
+<source>k-v</source>
+`
+
+// DanmakuArchiver 把弹幕保存为b站视频弹幕格式的XML，可以在播放录像时加载
+// 每场直播一个文件，文件名为场次ID（与Session.ID相同），弹幕时间为相对开播时间的秒数
+// 礼物、上船、醒目留言使用<gift>、<guard>、<sc>标签记录，播放器会忽略这些标签
+type DanmakuArchiver struct {
+	Dir        string                      // 保存目录，默认当前目录
+	Gifts      bool                        // 记录礼物和上船
+	SuperChats bool                        // 记录醒目留言
+	OnClose    func(int, string)           // 文件写完通知，参数为房间ID和文件路径
+	StartTime  func(int) (time.Time, bool) // 中途加入时获取开播时间，未开播时返回false，这时的弹幕不会保存，Live会自动设置
+
+	sync.Mutex
+	files map[int]*archiveFile
+}
+
+type archiveFile struct {
+	file   *os.File
+	writer *bufio.Writer
+	path   string
+	start  time.Time
+}
+
+// Close 结束所有文件，程序退出前调用
+func (a *DanmakuArchiver) Close() error {
+	a.Lock()
+	files := a.files
+	a.files = nil
+	a.Unlock()
+	var result error
+	for roomID, f := range files {
+		if err := a.finish(roomID, f); err != nil && result == nil {
+			result = err
+		}
+	}
+	return result
+}
+
+// 开播时新建文件，之前的文件会被结束
+func (a *DanmakuArchiver) open(roomID int, room *Room, start time.Time) error {
+	f, err := a.create(roomID, room, start)
+	if err != nil {
+		return err
+	}
+	a.Lock()
+	if a.files == nil {
+		a.files = make(map[int]*archiveFile)
+	}
+	previous, ok := a.files[roomID]
+	a.files[roomID] = f
+	a.Unlock()
+	if ok {
+		return a.finish(roomID, previous)
+	}
+	return nil
+}
+
+// 新建文件并写入文件头
+func (a *DanmakuArchiver) create(roomID int, room *Room, start time.Time) (*archiveFile, error) {
+	path := filepath.Join(a.Dir, sessionID(roomID, room, start)+".xml")
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, err
+	}
+	f := &archiveFile{
+		file:   file,
+		writer: bufio.NewWriter(file),
+		path:   path,
+		start:  start,
+	}
+	_, _ = f.writer.WriteString(danmakuXMLHeader)
+	return f, nil
+}
+
+// 下播时结束文件
+func (a *DanmakuArchiver) close(roomID int) error {
+	a.Lock()
+	f, ok := a.files[roomID]
+	delete(a.files, roomID)
+	a.Unlock()
+	if !ok {
+		return nil
+	}
+	return a.finish(roomID, f)
+}
+
+func (a *DanmakuArchiver) finish(roomID int, f *archiveFile) error {
+	_, _ = f.writer.WriteString("</i>\n")
+	err := f.writer.Flush()
+	if closeErr := f.file.Close(); err == nil {
+		err = closeErr
+	}
+	if a.OnClose != nil {
+		a.OnClose(roomID, f.path)
+	}
+	return err
+}
+
+// 写入一行，房间没有打开的文件时按开播时间新建，未开播时丢弃
+func (a *DanmakuArchiver) write(roomID int, room *Room, line func(start time.Time) string) {
+	a.Lock()
+	defer a.Unlock()
+	f, ok := a.files[roomID]
+	if !ok {
+		start := time.Now()
+		if a.StartTime != nil {
+			var live bool
+			if start, live = a.StartTime(roomID); !live {
+				return
+			}
+		}
+		var err error
+		if f, err = a.create(roomID, room, start); err != nil {
+			return
+		}
+		if a.files == nil {
+			a.files = make(map[int]*archiveFile)
+		}
+		a.files[roomID] = f
+	}
+	_, _ = f.writer.WriteString(line(f.start))
+	_ = f.writer.Flush()
+}
+
+// 相对开播时间的秒数
+func archiveOffset(start time.Time, t time.Time) float64 {
+	offset := t.Sub(start).Seconds()
+	if offset < 0 {
+		return 0
+	}
+	return offset
+}
+
+func (a *DanmakuArchiver) onMsg(roomID int, m *MsgModel) {
+	sendTime := time.Now()
+	if m.SendTime > 0 {
+		sendTime = time.Unix(0, m.SendTime*int64(time.Millisecond))
+	}
+	mode, fontSize, color := m.Mode, m.FontSize, m.Color
+	if mode == 0 {
+		mode = DanmakuModeScroll
+	}
+	if fontSize == 0 {
+		fontSize = 25
+	}
+	a.write(roomID, m.Room, func(start time.Time) string {
+		// p: 时间,模式,字号,颜色,发送时间戳,弹幕池,用户hash,弹幕ID
+		return fmt.Sprintf("<d p=\"%.3f,%d,%d,%d,%d,0,%s,%s\">%s</d>\n",
+			archiveOffset(start, sendTime), mode, fontSize, color, sendTime.Unix(),
+			xmlEscape(m.UserHash), xmlEscape(m.ID), xmlEscape(m.Content))
+	})
+}
+
+func (a *DanmakuArchiver) onGift(roomID int, m *GiftModel) {
+	if !a.Gifts {
+		return
+	}
+	now := time.Now()
+	a.write(roomID, m.Room, func(start time.Time) string {
+		return fmt.Sprintf("<gift ts=\"%.3f\" user=\"%s\" uid=\"%d\" giftname=\"%s\" giftcount=\"%d\"></gift>\n",
+			archiveOffset(start, now), xmlEscape(m.UserName), m.UserID, xmlEscape(m.GiftName), m.Num)
+	})
+}
+
+func (a *DanmakuArchiver) onGuard(roomID int, m *GuardBuyModel) {
+	if !a.Gifts {
+		return
+	}
+	now := time.Now()
+	a.write(roomID, m.Room, func(start time.Time) string {
+		return fmt.Sprintf("<guard ts=\"%.3f\" user=\"%s\" uid=\"%d\" level=\"%d\" count=\"%d\"></guard>\n",
+			archiveOffset(start, now), xmlEscape(m.UserName), m.UserID, m.GuardLevel, m.Num)
+	})
+}
+
+func (a *DanmakuArchiver) onSuperChat(roomID int, m *SuperChatMessageModel) {
+	if !a.SuperChats {
+		return
+	}
+	now := time.Now()
+	a.write(roomID, m.Room, func(start time.Time) string {
+		return fmt.Sprintf("<sc ts=\"%.3f\" user=\"%s\" uid=\"%d\" price=\"%d\" time=\"%d\">%s</sc>\n",
+			archiveOffset(start, now), xmlEscape(m.UserInfo.UserName), m.UserID, m.Price, m.Time, xmlEscape(m.Message))
+	})
+}
+
+func xmlEscape(s string) string {
+	b := &strings.Builder{}
+	_ = xml.EscapeText(b, []byte(s))
+	return b.String()
+}
+
+// 中途加入时的开播时间，还不知道直播状态时使用当前时间，已知未开播时返回false
+func (live *Live) archiveStartTime(roomID int) (time.Time, bool) {
+	status := live.Status(roomID)
+	if status == nil {
+		return time.Now(), true
+	}
+	return status.LiveTime, status.IsLive()
+}
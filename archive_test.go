@@ -0,0 +1,80 @@
+package bililive
+
+import (
+	"encoding/xml"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type archiveTestXML struct {
+	D []struct {
+		P       string `xml:"p,attr"`
+		Content string `xml:",chardata"`
+	} `xml:"d"`
+	SC []struct {
+		Price   int    `xml:"price,attr"`
+		Content string `xml:",chardata"`
+	} `xml:"sc"`
+}
+
+func TestArchiverSession(t *testing.T) {
+	dir, err := ioutil.TempDir("", "archive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var closed []string
+	archiver := &DanmakuArchiver{
+		Dir:        dir,
+		SuperChats: true,
+		OnClose:    func(_ int, path string) { closed = append(closed, path) },
+	}
+	live := &Live{Archiver: archiver, statuses: newStatusTracker()}
+	archiver.StartTime = live.archiveStartTime
+	room := &Room{RoomID: 3, RealRoomID: 23058, ShortID: 3}
+	start := time.Now().Add(-10 * time.Second).Truncate(time.Second)
+
+	live.setStatus(3, room, statusUpdate{liveStatus: LiveStatusLive, liveTime: start, source: StatusSourcePush})
+	archiver.onMsg(3, &MsgModel{Content: "a<b", Mode: DanmakuModeTop, FontSize: 25, Color: 0xFF0000, UserHash: "hash", ID: "1", SendTime: start.Add(2500*time.Millisecond).UnixNano() / 1e6, Room: room})
+	sc := &SuperChatMessageModel{Price: 30, Message: "sc", Room: room}
+	archiver.onSuperChat(3, sc)
+	live.setStatus(3, room, statusUpdate{liveStatus: LiveStatusPreparing, reason: EndReasonNormal, source: StatusSourcePush})
+
+	if len(closed) != 1 {
+		t.Fatalf("OnClose called %d times", len(closed))
+	}
+	if want := filepath.Join(dir, sessionID(3, room, start)+".xml"); closed[0] != want {
+		t.Fatalf("path %s, want %s", closed[0], want)
+	}
+	data, err := ioutil.ReadFile(closed[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := &archiveTestXML{}
+	if err := xml.Unmarshal(data, v); err != nil {
+		t.Fatal(err)
+	}
+	if len(v.D) != 1 || len(v.SC) != 1 {
+		t.Fatalf("%+v", v)
+	}
+	if v.D[0].Content != "a<b" || v.SC[0].Price != 30 {
+		t.Fatalf("%+v", v)
+	}
+	d := parseDanmakuP(v.D[0].P, v.D[0].Content)
+	if d.Offset != 2500*time.Millisecond || d.Mode != DanmakuModeTop || d.Color != 0xFF0000 || d.UserHash != "hash" || d.ID != "1" {
+		t.Fatalf("%+v", d)
+	}
+
+	// 下播后的弹幕不保存
+	archiver.onMsg(3, &MsgModel{Content: "after", Room: room})
+	if err := archiver.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(closed) != 1 {
+		t.Fatalf("file created after end: %v", closed)
+	}
+}
@@ -0,0 +1,424 @@
+package bililive
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ASS字幕默认参数
+const (
+	defaultASSWidth          = 1920
+	defaultASSHeight         = 1080
+	defaultASSFontName       = "Microsoft YaHei"
+	defaultASSFontSize       = 48
+	defaultASSScrollDuration = 8 * time.Second
+	defaultASSFixedDuration  = 4 * time.Second
+	defaultASSOpacity        = 0.8
+	danmakuBaseFontSize      = 25 // 弹幕的标准字号
+)
+
+// Danmaku 弹幕文件中的一条弹幕
+type Danmaku struct {
+	Offset    time.Duration // 相对开播时间
+	Mode      int           // 弹幕模式，见DanmakuMode*
+	FontSize  int           // 字号，25为标准字号
+	Color     int           // 颜色
+	SendTime  time.Time     // 发送时间
+	UserHash  string        // 用户hash
+	ID        string        // 弹幕ID
+	Content   string        // 内容
+	SuperChat bool          // 是否为DanmakuArchiver记录的醒目留言
+	UserName  string        // 醒目留言的用户名
+	Price     int           // 醒目留言的价格，元
+}
+
+// ASSOptions ASS字幕参数
+type ASSOptions struct {
+	Width          int           // 视频宽度，默认1920
+	Height         int           // 视频高度，默认1080
+	FontName       string        // 字体，默认Microsoft YaHei
+	FontSize       int           // 标准字号弹幕的字体大小，默认48
+	Opacity        float64       // 不透明度0-1，默认0.8
+	ScrollDuration time.Duration // 滚动弹幕经过屏幕的时间，默认8秒
+	FixedDuration  time.Duration // 顶部、底部弹幕停留的时间，默认4秒
+	ScrollArea     float64       // 滚动弹幕可以使用的屏幕高度比例0-1，默认1
+	MaxOnScreen    int           // 同时显示的弹幕数量上限，0不限制
+	Offset         time.Duration // 整体偏移，录像晚于开播时设置为负的时间差
+	SuperChats     bool          // 把醒目留言显示为顶部弹幕
+}
+
+func (o *ASSOptions) withDefaults() ASSOptions {
+	opts := ASSOptions{}
+	if o != nil {
+		opts = *o
+	}
+	if opts.Width <= 0 {
+		opts.Width = defaultASSWidth
+	}
+	if opts.Height <= 0 {
+		opts.Height = defaultASSHeight
+	}
+	if opts.FontName == "" {
+		opts.FontName = defaultASSFontName
+	}
+	if opts.FontSize <= 0 {
+		opts.FontSize = defaultASSFontSize
+	}
+	if opts.Opacity <= 0 || opts.Opacity > 1 {
+		opts.Opacity = defaultASSOpacity
+	}
+	if opts.ScrollDuration <= 0 {
+		opts.ScrollDuration = defaultASSScrollDuration
+	}
+	if opts.FixedDuration <= 0 {
+		opts.FixedDuration = defaultASSFixedDuration
+	}
+	if opts.ScrollArea <= 0 || opts.ScrollArea > 1 {
+		opts.ScrollArea = 1
+	}
+	return opts
+}
+
+// ParseDanmakuXML 读取b站弹幕XML，DanmakuArchiver保存的文件还会读取其中的醒目留言
+func ParseDanmakuXML(r io.Reader) ([]*Danmaku, error) {
+	decoder := xml.NewDecoder(r)
+	decoder.Strict = false
+	var result []*Danmaku
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return result, err
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch start.Name.Local {
+		case "d":
+			var element struct {
+				P       string `xml:"p,attr"`
+				Content string `xml:",chardata"`
+			}
+			if err := decoder.DecodeElement(&element, &start); err != nil {
+				return result, err
+			}
+			if d := parseDanmakuP(element.P, element.Content); d != nil {
+				result = append(result, d)
+			}
+		case "sc":
+			var element struct {
+				TS      float64 `xml:"ts,attr"`
+				User    string  `xml:"user,attr"`
+				Price   int     `xml:"price,attr"`
+				Content string  `xml:",chardata"`
+			}
+			if err := decoder.DecodeElement(&element, &start); err != nil {
+				return result, err
+			}
+			result = append(result, &Danmaku{
+				Offset:    time.Duration(element.TS * float64(time.Second)),
+				Mode:      DanmakuModeTop,
+				FontSize:  danmakuBaseFontSize,
+				Color:     0xFFD700,
+				Content:   element.Content,
+				SuperChat: true,
+				UserName:  element.User,
+				Price:     element.Price,
+			})
+		}
+	}
+	return result, nil
+}
+
+// p: 时间,模式,字号,颜色,发送时间戳,弹幕池,用户hash,弹幕ID
+func parseDanmakuP(p string, content string) *Danmaku {
+	fields := strings.Split(p, ",")
+	if len(fields) < 4 {
+		return nil
+	}
+	offset, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return nil
+	}
+	d := &Danmaku{
+		Offset:  time.Duration(offset * float64(time.Second)),
+		Content: content,
+	}
+	d.Mode, _ = strconv.Atoi(fields[1])
+	d.FontSize, _ = strconv.Atoi(fields[2])
+	d.Color, _ = strconv.Atoi(fields[3])
+	if len(fields) > 4 {
+		if ts, err := strconv.ParseInt(fields[4], 10, 64); err == nil && ts > 0 {
+			d.SendTime = time.Unix(ts, 0)
+		}
+	}
+	if len(fields) > 6 {
+		d.UserHash = fields[6]
+	}
+	if len(fields) > 7 {
+		d.ID = fields[7]
+	}
+	return d
+}
+
+// ConvertDanmakuFile 把弹幕XML文件转换为ASS字幕文件
+func ConvertDanmakuFile(xmlPath string, assPath string, opts *ASSOptions) error {
+	in, err := os.Open(xmlPath)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	danmaku, err := ParseDanmakuXML(in)
+	if err != nil {
+		return err
+	}
+	out, err := os.Create(assPath)
+	if err != nil {
+		return err
+	}
+	if err := RenderASS(out, danmaku, opts); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// 屏幕上的一条弹幕
+type assItem struct {
+	start  time.Duration
+	end    time.Duration
+	width  float64 // 估算的文字宽度
+	height float64
+}
+
+// 滚动弹幕完全进入屏幕的时间
+func (i *assItem) entered(screenWidth float64) time.Duration {
+	return i.start + time.Duration(float64(i.end-i.start)*i.width/(screenWidth+i.width))
+}
+
+// 滚动弹幕到达屏幕左边缘的时间
+func (i *assItem) reachLeft(screenWidth float64) time.Duration {
+	return i.start + time.Duration(float64(i.end-i.start)*screenWidth/(screenWidth+i.width))
+}
+
+// 弹幕轨道，按行记录最后一条弹幕
+type assLanes struct {
+	rows []*assItem
+}
+
+func newASSLanes(n int) *assLanes {
+	if n < 1 {
+		n = 1
+	}
+	return &assLanes{rows: make([]*assItem, n)}
+}
+
+// 找到能放下弹幕的连续n行，返回第一行，没有时返回-1
+func (l *assLanes) find(n int, free func(last *assItem) bool) int {
+	for row := 0; row+n <= len(l.rows); row++ {
+		ok := true
+		for i := row; i < row+n; i++ {
+			if last := l.rows[i]; last != nil && !free(last) {
+				ok = false
+				row = i
+				break
+			}
+		}
+		if ok {
+			return row
+		}
+	}
+	return -1
+}
+
+func (l *assLanes) put(row int, n int, item *assItem) {
+	for i := row; i < row+n; i++ {
+		l.rows[i] = item
+	}
+}
+
+// RenderASS 把弹幕排列到互不重叠的轨道上，输出ASS字幕
+// 滚动弹幕不会追上同一轨道的前一条，顶部、底部弹幕不会覆盖，放不下或超过MaxOnScreen的弹幕会被丢弃
+// 高级弹幕和代码弹幕不支持，会被忽略
+func RenderASS(w io.Writer, danmaku []*Danmaku, opts *ASSOptions) error {
+	o := opts.withDefaults()
+	sorted := make([]*Danmaku, 0, len(danmaku))
+	for _, d := range danmaku {
+		if d.SuperChat && !o.SuperChats {
+			continue
+		}
+		sorted = append(sorted, d)
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Offset < sorted[j].Offset
+	})
+
+	writer := bufio.NewWriter(w)
+	writeASSHeader(writer, &o)
+
+	screenWidth := float64(o.Width)
+	rowHeight := float64(o.FontSize)
+	rows := int(float64(o.Height) / rowHeight)
+	scroll := newASSLanes(int(float64(o.Height) * o.ScrollArea / rowHeight))
+	top := newASSLanes(rows)
+	bottom := newASSLanes(rows)
+	var onScreen []time.Duration
+
+	for _, d := range sorted {
+		start := d.Offset + o.Offset
+		if start < 0 {
+			continue
+		}
+		var lanes *assLanes
+		duration := o.FixedDuration
+		switch d.Mode {
+		case 1, 2, 3, 6: // 滚动、逆向弹幕
+			lanes = scroll
+			duration = o.ScrollDuration
+		case DanmakuModeBottom:
+			lanes = bottom
+		case DanmakuModeTop:
+			lanes = top
+		default:
+			continue
+		}
+		// 同时显示的数量
+		if o.MaxOnScreen > 0 {
+			visible := onScreen[:0]
+			for _, end := range onScreen {
+				if end > start {
+					visible = append(visible, end)
+				}
+			}
+			onScreen = visible
+			if len(onScreen) >= o.MaxOnScreen {
+				continue
+			}
+		}
+
+		text := d.Content
+		if d.SuperChat {
+			text = fmt.Sprintf("【SC ¥%d】%s：%s", d.Price, d.UserName, d.Content)
+		}
+		fontSize := o.FontSize
+		if d.FontSize > 0 && d.FontSize != danmakuBaseFontSize {
+			fontSize = int(math.Round(float64(o.FontSize) * float64(d.FontSize) / danmakuBaseFontSize))
+		}
+		width, lines := assTextSize(text, float64(fontSize))
+		item := &assItem{
+			start:  start,
+			end:    start + duration,
+			width:  width,
+			height: lines * float64(fontSize),
+		}
+		n := int(math.Ceil(item.height / rowHeight))
+		var row int
+		if lanes == scroll {
+			row = lanes.find(n, func(last *assItem) bool {
+				// 前一条已经完全进入屏幕，并且到达左边缘前前一条已经离开
+				return start >= last.entered(screenWidth) && item.reachLeft(screenWidth) >= last.end
+			})
+		} else {
+			row = lanes.find(n, func(last *assItem) bool {
+				return start >= last.end
+			})
+		}
+		if row < 0 {
+			continue
+		}
+		lanes.put(row, n, item)
+		if o.MaxOnScreen > 0 {
+			onScreen = append(onScreen, item.end)
+		}
+
+		tags := ""
+		y := float64(row) * rowHeight
+		switch lanes {
+		case scroll:
+			tags = fmt.Sprintf("\\move(%d,%d,%d,%d)", o.Width, int(y), -int(math.Ceil(width)), int(y))
+		case top:
+			tags = fmt.Sprintf("\\an8\\pos(%d,%d)", o.Width/2, int(y))
+		case bottom:
+			tags = fmt.Sprintf("\\an2\\pos(%d,%d)", o.Width/2, o.Height-int(y))
+		}
+		if fontSize != o.FontSize {
+			tags += fmt.Sprintf("\\fs%d", fontSize)
+		}
+		if color := d.Color & 0xFFFFFF; color != 0xFFFFFF {
+			tags += "\\c&H" + assColor(color) + "&"
+			// 深色文字使用白色描边
+			if r, g, b := color>>16, color>>8&0xFF, color&0xFF; r*299+g*587+b*114 < 60000 {
+				tags += "\\3c&HFFFFFF&"
+			}
+		}
+		fmt.Fprintf(writer, "Dialogue: 2,%s,%s,Danmaku,,0,0,0,,{%s}%s\n",
+			assTime(item.start), assTime(item.end), tags, assEscape(text))
+	}
+	return writer.Flush()
+}
+
+func writeASSHeader(w io.Writer, o *ASSOptions) {
+	alpha := fmt.Sprintf("%02X", int(math.Round(255*(1-o.Opacity))))
+	fmt.Fprintf(w, `[Script Info]
+ScriptType: v4.00+
+PlayResX: %d
+PlayResY: %d
+WrapStyle: 2
+ScaledBorderAndShadow: yes
+
+[V4+ Styles]
+Format: Name, Fontname, Fontsize, PrimaryColour, SecondaryColour, OutlineColour, BackColour, Bold, Italic, Underline, StrikeOut, ScaleX, ScaleY, Spacing, Angle, BorderStyle, Outline, Shadow, Alignment, MarginL, MarginR, MarginV, Encoding
+Style: Danmaku,%s,%d,&H%sFFFFFF,&H%sFFFFFF,&H%s000000,&H%s000000,0,0,0,0,100,100,0,0,1,%d,0,7,0,0,0,1
+
+[Events]
+Format: Layer, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text
+`, o.Width, o.Height, o.FontName, o.FontSize, alpha, alpha, alpha, alpha, int(math.Max(1, float64(o.FontSize)/25)))
+}
+
+// 估算文字宽度和行数，全角字符按一个字号宽，半角按半个字号宽
+func assTextSize(text string, fontSize float64) (float64, float64) {
+	lines := strings.Split(text, "\n")
+	var width float64
+	for _, line := range lines {
+		var w float64
+		for _, r := range line {
+			if r >= 0x2E80 {
+				w += fontSize
+			} else {
+				w += fontSize / 2
+			}
+		}
+		if w > width {
+			width = w
+		}
+	}
+	return width, float64(len(lines))
+}
+
+// ASS颜色为BBGGRR
+func assColor(color int) string {
+	return fmt.Sprintf("%02X%02X%02X", color&0xFF, color>>8&0xFF, color>>16&0xFF)
+}
+
+// ASS时间 h:mm:ss.cc
+func assTime(d time.Duration) string {
+	cs := int64(d / (10 * time.Millisecond))
+	return fmt.Sprintf("%d:%02d:%02d.%02d", cs/360000, cs/6000%60, cs/100%60, cs%100)
+}
+
+func assEscape(text string) string {
+	// 反斜杠后插入零宽字符，避免被当作转义
+	text = strings.NewReplacer("\\", "\\\u2060", "{", "\\{", "}", "\\}", "\r", "").Replace(text)
+	return strings.Replace(text, "\n", "\\N", -1)
+}
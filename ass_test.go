@@ -0,0 +1,250 @@
+package bililive
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type assTestLine struct {
+	start, end string
+	kind       string // move、top、bottom
+	y          int
+	text       string
+}
+
+var (
+	assDialogue = regexp.MustCompile(`^Dialogue: 2,([^,]+),([^,]+),Danmaku,,0,0,0,,\{([^}]*)\}(.*)$`)
+	assMove     = regexp.MustCompile(`\\move\(\d+,(\d+),-?\d+,\d+\)`)
+	assPos      = regexp.MustCompile(`\\an(\d)\\pos\(\d+,(\d+)\)`)
+)
+
+func renderTestASS(t *testing.T, danmaku []*Danmaku, opts *ASSOptions) []assTestLine {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	if err := RenderASS(buf, danmaku, opts); err != nil {
+		t.Fatal(err)
+	}
+	var result []assTestLine
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if !strings.HasPrefix(line, "Dialogue:") {
+			continue
+		}
+		m := assDialogue.FindStringSubmatch(line)
+		if m == nil {
+			t.Fatalf("bad dialogue %q", line)
+		}
+		l := assTestLine{start: m[1], end: m[2], text: m[4]}
+		if move := assMove.FindStringSubmatch(m[3]); move != nil {
+			l.kind = "move"
+			l.y, _ = strconv.Atoi(move[1])
+		} else if pos := assPos.FindStringSubmatch(m[3]); pos != nil {
+			l.kind = map[string]string{"8": "top", "2": "bottom"}[pos[1]]
+			l.y, _ = strconv.Atoi(pos[2])
+		} else {
+			t.Fatalf("no position %q", line)
+		}
+		result = append(result, l)
+	}
+	return result
+}
+
+func TestRenderASSLanes(t *testing.T) {
+	var danmaku []*Danmaku
+	for i := 0; i < 6; i++ {
+		for _, mode := range []int{DanmakuModeScroll, DanmakuModeTop, DanmakuModeBottom} {
+			danmaku = append(danmaku, &Danmaku{Offset: time.Second, Mode: mode, FontSize: 25, Content: "同时发送的弹幕" + strconv.Itoa(i)})
+		}
+	}
+	// 大字号占两行
+	danmaku = append(danmaku, &Danmaku{Offset: time.Second, Mode: DanmakuModeTop, FontSize: 50, Content: "大"})
+	lines := renderTestASS(t, danmaku, nil)
+	if len(lines) != len(danmaku) {
+		t.Fatalf("%d lines, want %d", len(lines), len(danmaku))
+	}
+	rows := map[string]map[int]bool{}
+	for _, l := range lines {
+		if rows[l.kind] == nil {
+			rows[l.kind] = map[int]bool{}
+		}
+		if rows[l.kind][l.y] {
+			t.Fatalf("%s row %d used twice", l.kind, l.y)
+		}
+		rows[l.kind][l.y] = true
+		if l.start != "0:00:01.00" {
+			t.Fatalf("start %s", l.start)
+		}
+	}
+	if len(rows["move"]) != 6 || len(rows["top"]) != 7 || len(rows["bottom"]) != 6 {
+		t.Fatalf("rows %v", rows)
+	}
+	// 大字号弹幕之后的两行都被占用
+	if last := lines[len(lines)-1]; last.y != 6*defaultASSFontSize {
+		t.Fatalf("large danmaku at %d", last.y)
+	}
+
+	// 前一条离开后轨道可以复用，追上前一条时换行
+	lines = renderTestASS(t, []*Danmaku{
+		{Offset: 0, Mode: DanmakuModeScroll, Content: "第一条"},
+		{Offset: 500 * time.Millisecond, Mode: DanmakuModeScroll, Content: "还没有完全进入屏幕时换行"},
+		{Offset: 3 * time.Second, Mode: DanmakuModeScroll, Content: "短"},
+		{Offset: 3 * time.Second, Mode: DanmakuModeTop, Content: "顶部"},
+		{Offset: 7 * time.Second, Mode: DanmakuModeTop, Content: "顶部消失后复用"},
+		{Offset: 7 * time.Second, Mode: DanmakuModeTop, Content: "同时的顶部"},
+	}, nil)
+	want := []int{0, defaultASSFontSize, 0, 0, 0, defaultASSFontSize}
+	for i, l := range lines {
+		if l.y != want[i] {
+			t.Fatalf("line %d at %d, want %d", i, l.y, want[i])
+		}
+	}
+}
+
+func TestRenderASSLimits(t *testing.T) {
+	var danmaku []*Danmaku
+	for i := 0; i < 10; i++ {
+		danmaku = append(danmaku, &Danmaku{Offset: time.Duration(i) * 10 * time.Millisecond, Mode: DanmakuModeScroll, Content: "弹幕"})
+	}
+	danmaku = append(danmaku, &Danmaku{Offset: 9 * time.Second, Mode: DanmakuModeScroll, Content: "之前的都离开了"})
+
+	// 同时显示的数量上限
+	lines := renderTestASS(t, danmaku, &ASSOptions{MaxOnScreen: 4})
+	if len(lines) != 5 || lines[4].text != "之前的都离开了" {
+		t.Fatalf("%+v", lines)
+	}
+	// 屏幕只有两行，放不下的弹幕被丢弃
+	lines = renderTestASS(t, danmaku, &ASSOptions{Height: 2 * defaultASSFontSize})
+	if len(lines) != 3 || lines[0].y == lines[1].y {
+		t.Fatalf("%+v", lines)
+	}
+	// 滚动区域只有一半
+	lines = renderTestASS(t, danmaku, &ASSOptions{Height: 4 * defaultASSFontSize, ScrollArea: 0.5})
+	if len(lines) != 3 {
+		t.Fatalf("%+v", lines)
+	}
+	// 整体偏移，开头之前的弹幕被丢弃
+	lines = renderTestASS(t, danmaku, &ASSOptions{Offset: -time.Second})
+	if len(lines) != 1 || lines[0].start != "0:00:08.00" {
+		t.Fatalf("%+v", lines)
+	}
+	// 不支持的高级弹幕
+	if lines := renderTestASS(t, []*Danmaku{{Mode: 7, Content: "[0,0]"}, {Mode: 8, Content: "code"}}, nil); len(lines) != 0 {
+		t.Fatalf("%+v", lines)
+	}
+}
+
+func TestRenderASSEscape(t *testing.T) {
+	lines := renderTestASS(t, []*Danmaku{{Mode: DanmakuModeTop, Content: "{\\pos(0,0)}\\N第一行\r\n第二行"}}, nil)
+	if len(lines) != 1 {
+		t.Fatal(lines)
+	}
+	if want := "\\{\\⁠pos(0,0)\\}\\⁠N第一行\\N第二行"; lines[0].text != want {
+		t.Fatalf("text %q, want %q", lines[0].text, want)
+	}
+	if assTime(time.Hour+2*time.Minute+3*time.Second+456*time.Millisecond) != "1:02:03.45" {
+		t.Fatal("time")
+	}
+	if assColor(0x123456) != "563412" {
+		t.Fatal("color")
+	}
+}
+
+func TestParseDanmakuXML(t *testing.T) {
+	danmaku, err := ParseDanmakuXML(strings.NewReader(`<?xml version="1.0" encoding="UTF-8"?><i><chatserver>chat.bilibili.com</chatserver>` +
+		`<d p="12.345,5,25,16711680,1622548800,0,abcdef,42">顶部&amp;红色</d>` +
+		`<d p="bad">坏的</d><d p="1,1,25,16777215">短格式</d></i>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(danmaku) != 2 {
+		t.Fatalf("%d danmaku", len(danmaku))
+	}
+	d := danmaku[0]
+	if d.Offset != 12345*time.Millisecond || d.Mode != DanmakuModeTop || d.Color != 0xFF0000 || d.SendTime.Unix() != 1622548800 ||
+		d.UserHash != "abcdef" || d.ID != "42" || d.Content != "顶部&红色" {
+		t.Fatalf("%+v", d)
+	}
+	if danmaku[1].Content != "短格式" || danmaku[1].Offset != time.Second {
+		t.Fatalf("%+v", danmaku[1])
+	}
+}
+
+// DanmakuArchiver保存的文件转换为字幕
+func TestArchiveToASS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	start := time.Now().Add(-time.Minute).Truncate(time.Second)
+	room := &Room{RoomID: 1000, RealRoomID: 1000}
+	var path string
+	archiver := &DanmakuArchiver{
+		Dir:        dir,
+		Gifts:      true,
+		SuperChats: true,
+		StartTime:  func(int) (time.Time, bool) { return start, true },
+		OnClose:    func(_ int, p string) { path = p },
+	}
+	send := func(offset time.Duration, mode int, content string) {
+		archiver.onMsg(1000, &MsgModel{Content: content, Mode: mode, FontSize: 25, Color: 0xFFFFFF, UserHash: "hash",
+			ID: "id", SendTime: start.Add(offset).UnixNano() / 1e6, Room: room})
+	}
+	send(time.Second, DanmakuModeScroll, "滚动<弹幕>")
+	send(1500*time.Millisecond, DanmakuModeTop, "顶部")
+	send(2*time.Second, DanmakuModeBottom, "底部")
+	archiver.onGift(1000, &GiftModel{UserName: "用户", GiftName: "辣条", Num: 1, Room: room})
+	archiver.onSuperChat(1000, &SuperChatMessageModel{UserID: 1, Price: 30, Time: 60, Message: "醒目&留言",
+		UserInfo: SuperChatUser{UserName: "用户"}, Room: room})
+	if err := archiver.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	danmaku, err := ParseDanmakuXML(f)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(danmaku) != 4 {
+		t.Fatalf("%d danmaku", len(danmaku))
+	}
+	if d := danmaku[0]; d.Offset != time.Second || d.Mode != DanmakuModeScroll || d.Content != "滚动<弹幕>" || d.UserHash != "hash" || d.ID != "id" {
+		t.Fatalf("%+v", d)
+	}
+	if danmaku[1].Mode != DanmakuModeTop || danmaku[2].Mode != DanmakuModeBottom {
+		t.Fatalf("%+v %+v", danmaku[1], danmaku[2])
+	}
+	if sc := danmaku[3]; !sc.SuperChat || sc.Price != 30 || sc.UserName != "用户" || sc.Content != "醒目&留言" || sc.Offset < time.Minute {
+		t.Fatalf("%+v", sc)
+	}
+
+	assPath := filepath.Join(dir, "out.ass")
+	if err := ConvertDanmakuFile(path, assPath, &ASSOptions{SuperChats: true}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(assPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := string(data)
+	for _, want := range []string{"PlayResX: 1920", "滚动<弹幕>", "{\\an8\\pos(960,0)}顶部", "{\\an2\\pos(960,1080)}底部", "【SC ¥30】用户：醒目&留言"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("missing %q", want)
+		}
+	}
+	// 默认不显示醒目留言
+	if lines := renderTestASS(t, danmaku, nil); len(lines) != 3 {
+		t.Fatalf("%d lines", len(lines))
+	}
+}
@@ -0,0 +1,291 @@
+package bililive
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 命令错误
+var (
+	ErrCommandPermission = errors.New("没有权限")
+	ErrCommandCooldown   = errors.New("命令冷却中")
+	ErrCommandArgs       = errors.New("参数不正确")
+)
+
+// 默认命令前缀
+var defaultCommandPrefixes = []string{"!", "！"}
+
+// Command 弹幕命令
+type Command struct {
+	Name           string                      // 命令名称
+	Aliases        []string                    // 别名
+	Usage          string                      // 用法说明
+	MinArgs        int                         // 最少参数数量
+	MaxArgs        int                         // 最多参数数量，0不限制，超过时多余部分合并到最后一个参数
+	Permission     *Permission                 // 权限，nil所有人可用
+	UserCooldown   time.Duration               // 同一用户的冷却时间
+	GlobalCooldown time.Duration               // 房间内的冷却时间
+	Handler        func(*CommandContext) error // 处理方法
+}
+
+// Permission 命令权限，满足任一条件即可使用
+type Permission struct {
+	Admin         bool    // 房管
+	GuardLevel    int     // 舰长等级，1总督 2提督 3舰长，等级数字小于等于该值的舰长可用
+	MinMedalLevel int     // 本房间粉丝勋章等级
+	AllowUsers    []int64 // 白名单用户
+}
+
+// CommandContext 命令上下文
+type CommandContext struct {
+	RoomID  int       // 房间ID
+	Msg     *MsgModel // 弹幕
+	Command *Command  // 命令
+	Name    string    // 使用的命令名称或别名
+	Args    []string  // 参数
+	Raw     string    // 命令名称之后的原始文本
+}
+
+// CommandRouter 弹幕命令路由，如"!点歌 晴天"
+type CommandRouter struct {
+	Prefixes []string                                 // 命令前缀，默认"!"和"！"
+	OnError  func(*CommandContext, error)             // 命令被拒绝或处理出错
+	Medal    func(roomID int, medalRoomID int64) bool // 判断粉丝勋章是否属于该房间，默认比较真实房间ID
+
+	sync.Mutex
+	commands  map[string]*Command
+	cooldowns map[string]time.Time
+}
+
+// NewCommandRouter 创建命令路由
+func NewCommandRouter(prefixes ...string) *CommandRouter {
+	return &CommandRouter{
+		Prefixes: prefixes,
+	}
+}
+
+// Register 注册命令，名称或别名重复时返回错误
+func (r *CommandRouter) Register(commands ...*Command) error {
+	r.Lock()
+	defer r.Unlock()
+	if r.commands == nil {
+		r.commands = make(map[string]*Command)
+	}
+	for _, cmd := range commands {
+		if cmd.Name == "" || cmd.Handler == nil {
+			return errors.New("命令名称和处理方法不能为空")
+		}
+		for _, name := range append([]string{cmd.Name}, cmd.Aliases...) {
+			if _, exist := r.commands[name]; exist {
+				return fmt.Errorf("命令 %s 已存在", name)
+			}
+		}
+		for _, name := range append([]string{cmd.Name}, cmd.Aliases...) {
+			r.commands[name] = cmd
+		}
+	}
+	return nil
+}
+
+// Unregister 移除命令及其别名
+func (r *CommandRouter) Unregister(name string) {
+	r.Lock()
+	defer r.Unlock()
+	cmd, ok := r.commands[name]
+	if !ok {
+		return
+	}
+	for _, n := range append([]string{cmd.Name}, cmd.Aliases...) {
+		delete(r.commands, n)
+	}
+}
+
+// Handle 处理弹幕，是命令时返回true
+// 命令名称使用规范化后的文本匹配，参数使用原始文本
+func (r *CommandRouter) Handle(roomID int, m *MsgModel) bool {
+	name, raw, ok := r.parse(m.Content)
+	cmd, found := r.lookup(name, ok)
+	if m.NormalizedContent != "" {
+		if text, rest, textOK := r.parse(m.NormalizedContent); textOK {
+			// 规范化去除了空格时名称和参数连在一起
+			joined := ok && raw != "" && rest == ""
+			if c, n, matched := r.match(text, joined); matched {
+				cmd, name, found = c, n, true
+				// 原始文本没有前缀时（如规范化前为其他写法），参数取规范化后的文本
+				if !ok {
+					raw = strings.TrimSpace(strings.TrimPrefix(text+" "+rest, n))
+				}
+			}
+		}
+	}
+	if !found {
+		return false
+	}
+
+	ctx := &CommandContext{
+		RoomID:  roomID,
+		Msg:     m,
+		Command: cmd,
+		Name:    name,
+		Raw:     raw,
+		Args:    splitArgs(raw, cmd.MaxArgs),
+	}
+	if err := r.check(ctx); err != nil {
+		r.fail(ctx, err)
+		return true
+	}
+	if err := cmd.Handler(ctx); err != nil {
+		r.fail(ctx, err)
+	}
+	return true
+}
+
+func (r *CommandRouter) lookup(name string, ok bool) (*Command, bool) {
+	if !ok {
+		return nil, false
+	}
+	r.Lock()
+	defer r.Unlock()
+	cmd, found := r.commands[name]
+	return cmd, found
+}
+
+// 按规范化后的名称匹配命令，prefix为true时取最长的命令名称前缀
+func (r *CommandRouter) match(text string, prefix bool) (*Command, string, bool) {
+	r.Lock()
+	defer r.Unlock()
+	if cmd, ok := r.commands[text]; ok {
+		return cmd, text, true
+	}
+	if !prefix {
+		return nil, "", false
+	}
+	var (
+		result *Command
+		name   string
+	)
+	for n, cmd := range r.commands {
+		if len(n) > len(name) && strings.HasPrefix(text, n) {
+			result, name = cmd, n
+		}
+	}
+	return result, name, result != nil
+}
+
+// 解析命令名称和参数文本
+func (r *CommandRouter) parse(content string) (string, string, bool) {
+	content = strings.TrimSpace(content)
+	prefixes := r.Prefixes
+	if len(prefixes) == 0 {
+		prefixes = defaultCommandPrefixes
+	}
+	for _, prefix := range prefixes {
+		if !strings.HasPrefix(content, prefix) {
+			continue
+		}
+		content = strings.TrimSpace(content[len(prefix):])
+		if content == "" {
+			return "", "", false
+		}
+		index := strings.IndexFunc(content, isArgSpace)
+		if index < 0 {
+			return content, "", true
+		}
+		return content[:index], strings.TrimSpace(content[index:]), true
+	}
+	return "", "", false
+}
+
+// 检查参数、权限和冷却
+func (r *CommandRouter) check(ctx *CommandContext) error {
+	cmd := ctx.Command
+	if len(ctx.Args) < cmd.MinArgs {
+		return ErrCommandArgs
+	}
+	if !r.permitted(ctx.RoomID, ctx.Msg, cmd.Permission) {
+		return ErrCommandPermission
+	}
+
+	now := time.Now()
+	userKey := fmt.Sprintf("%d:%s:%d", ctx.RoomID, cmd.Name, ctx.Msg.UserID)
+	globalKey := fmt.Sprintf("%d:%s", ctx.RoomID, cmd.Name)
+	r.Lock()
+	defer r.Unlock()
+	if r.cooldowns == nil {
+		r.cooldowns = make(map[string]time.Time)
+	}
+	if len(r.cooldowns) > 1024 {
+		for k, t := range r.cooldowns {
+			if now.After(t) {
+				delete(r.cooldowns, k)
+			}
+		}
+	}
+	if now.Before(r.cooldowns[globalKey]) || now.Before(r.cooldowns[userKey]) {
+		return ErrCommandCooldown
+	}
+	if cmd.GlobalCooldown > 0 {
+		r.cooldowns[globalKey] = now.Add(cmd.GlobalCooldown)
+	}
+	if cmd.UserCooldown > 0 {
+		r.cooldowns[userKey] = now.Add(cmd.UserCooldown)
+	}
+	return nil
+}
+
+// 是否有权限
+func (r *CommandRouter) permitted(roomID int, m *MsgModel, p *Permission) bool {
+	if p == nil {
+		return true
+	}
+	for _, id := range p.AllowUsers {
+		if id == m.UserID {
+			return true
+		}
+	}
+	if p.Admin && m.IsAdmin {
+		return true
+	}
+	if p.GuardLevel > 0 && m.GuardLevel > 0 && m.GuardLevel <= p.GuardLevel {
+		return true
+	}
+	if p.MinMedalLevel > 0 && m.MedalLevel >= p.MinMedalLevel {
+		if r.Medal != nil {
+			return r.Medal(roomID, m.MedalRoomID)
+		}
+		if m.Room != nil {
+			return m.MedalRoomID == int64(m.Room.RealRoomID)
+		}
+		return m.MedalRoomID == int64(roomID)
+	}
+	return false
+}
+
+func (r *CommandRouter) fail(ctx *CommandContext, err error) {
+	if r.OnError != nil {
+		r.OnError(ctx, err)
+	}
+}
+
+// 按空白拆分参数，max大于0时多余部分合并到最后一个参数
+func splitArgs(raw string, max int) []string {
+	args := strings.FieldsFunc(raw, isArgSpace)
+	if max <= 0 || len(args) <= max {
+		return args
+	}
+	result := args[:max-1]
+	rest := raw
+	for _, arg := range result {
+		rest = strings.TrimLeftFunc(rest, isArgSpace)
+		rest = rest[len(arg):]
+	}
+	return append(result, strings.TrimSpace(rest))
+}
+
+// 参数分隔符，包含全角空格
+func isArgSpace(r rune) bool {
+	return r == ' ' || r == '\t' || r == '　'
+}
@@ -0,0 +1,38 @@
+package bililive
+
+import "testing"
+
+func TestCommandNormalized(t *testing.T) {
+	var got *CommandContext
+	r := NewCommandRouter()
+	if err := r.Register(&Command{Name: "点歌", MinArgs: 1, Handler: func(ctx *CommandContext) error {
+		got = ctx
+		return nil
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		normalizer *Normalizer
+		content    string
+	}{
+		{nil, "!点歌 晴天"},
+		{DefaultNormalizer, "!點歌 晴天"},
+		{&Normalizer{Width: true, Traditional: true}, "！點歌　晴天"},
+	}
+	for _, c := range cases {
+		got = nil
+		m := &MsgModel{Content: c.content}
+		if c.normalizer != nil {
+			m.NormalizedContent = c.normalizer.Normalize(m.Content)
+		}
+		if !r.Handle(1, m) || got == nil {
+			t.Fatalf("%q not handled", c.content)
+		}
+		if got.Name != "点歌" || got.Raw != "晴天" || len(got.Args) != 1 || got.Args[0] != "晴天" {
+			t.Fatalf("%q: name=%q raw=%q args=%q", c.content, got.Name, got.Raw, got.Args)
+		}
+	}
+	if r.Handle(1, &MsgModel{Content: "!点歌晴天"}) {
+		t.Fatal("command name must be followed by a space")
+	}
+}
@@ -26,6 +26,10 @@ func main() {
 		Debug:              false, // 不输出日志
 		AnalysisRoutineNum: 1,     // 消息分析协程数量，默认为1，为1可以保证通知顺序与接收到消息顺序相同
 		StormFilter:        true,  // 过滤节奏风暴弹幕
+		RevenueLedger:      true,  // 统计每场直播的收益
+		RevenueSummary: func(roomID int, m *bililive.RevenueSummary) {
+			log.Printf("【收益汇总】本场直播收益 %.2f 元，礼物 %.2f 元，上船 %.2f 元，醒目留言 %.2f 元", m.Total.CNY, m.ByCategory[bililive.RevenueGift].CNY, m.ByCategory[bililive.RevenueGuard].CNY, m.ByCategory[bililive.RevenueSuperChat].CNY)
+		},
 		Live: func(roomID int) {
 			log.Println("【直播开始】")
 		},
@@ -57,6 +61,10 @@ func main() {
 				coin = "金瓜子"
 				log.Printf("【礼物通知】%s(%v):  %s(%d) * %d [价值 %d个%s]", gift.UserName, time.Unix(gift.Timestamp, 0), gift.GiftName, gift.GiftID, gift.Num, gift.Price*gift.Num, coin)
 			}
+			if gift.IsBlindGift() {
+				log.Printf("【盲盒通知】%s:  %s * %d 开出 %s [支付 %d个%s，价值 %d个%s]", gift.UserName, gift.BlindGift.OriginalGiftName, gift.Num, gift.GiftName, gift.PaidCoin(), coin, gift.RevealedCoin(), coin)
+				return
+			}
 			log.Printf("【礼物通知】%s:  %s(%d) * %d [价值 %d个%s]", gift.UserName, gift.GiftName, gift.GiftID, gift.Num, gift.Price*gift.Num, coin)
 		},
 		GiftComboSend: func(roomID int, m *bililive.ComboSendModel) {
@@ -65,8 +73,11 @@ func main() {
 		GiftComboEnd: func(roomID int, m *bililive.ComboEndModel) {
 			log.Printf("【连击结束】%v 赠送 %v(价值%v) 总共连击 %v 次", m.UserName, m.GiftName, m.Price, m.ComboNum)
 		},
+		GiftCombo: func(roomID int, m *bililive.GiftComboModel) { // 设置后启用礼物合并
+			log.Printf("【礼物合并】%v 赠送 %v * %v [价值 %d个瓜子]", m.UserName, m.GiftName, m.Num, m.TotalCoin)
+		},
 		GuardBuy: func(roomID int, m *bililive.GuardBuyModel) {
-			log.Printf("【用户上船】欢迎 %v - %v(%v) 上船", m.GiftName, m.UserName, m.UserID)
+			log.Printf("【用户上船】欢迎 %v - %v(%v) 上船 [价值 %.2f 元]", m.GiftName, m.UserName, m.UserID, m.Value().CNY)
 		},
 		FansUpdate: func(roomID int, m *bililive.FansUpdateModel) {
 			log.Printf("【粉丝更新】当前粉丝数 %d", m.Fans)
@@ -80,7 +91,13 @@ func main() {
 			}
 		},
 		SuperChatMessage: func(roomID int, m *bililive.SuperChatMessageModel) {
-			log.Printf("【醒目留言】%s ：%s | 价值 %d 元", m.UserInfo.UserName, m.Message, m.Price)
+			log.Printf("【醒目留言】%d %s ：%s | 价值 %d 元", m.ID, m.UserInfo.UserName, m.Message, m.Price)
+		},
+		SuperChatMessageJPN: func(roomID int, m *bililive.SuperChatMessageModel) {
+			log.Printf("【醒目留言翻译】%d %s ：%s", m.ID, m.UserInfo.UserName, m.MessageJPN)
+		},
+		SuperChatDelete: func(roomID int, m *bililive.SuperChatDeleteModel) {
+			log.Printf("【醒目留言删除】%v", m.IDs)
 		},
 	}
 	live.Start(context.Background())
@@ -27,24 +27,14 @@ func main() {
 	if roomID <= 0 {
 		log.Fatalln("房间号错误!")
 	}
-	m := &memberModel{}
-	m.Reset()
-	var point string
-	var run bool
-	go startWeb(m, &point, &run)
 	live := &bililive.Live{
 		Debug:       false,
 		StormFilter: true, // 过滤节奏风暴弹幕
-		ReceiveMsg: func(roomID int, msg *bililive.MsgModel) {
-			// log.Printf("【弹幕】%v:  %v", msg.UserName, msg.Content)
-			if run && point != "" && msg.Content == point {
-				m.Add(msg.UserID, msg.UserName)
-			}
-		},
 		ReceivePopularValue: func(roomID int, value uint32) {
 			log.Printf("【人气】:  %v", value)
 		},
 	}
+	go startWeb(&drawModel{live: live, roomID: roomID})
 	fmt.Println()
 	_ = open("http://127.0.0.1:8080/html")
 	fmt.Println("浏览器输入 http://127.0.0.1:8080/html 访问...")
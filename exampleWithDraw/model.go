@@ -1,33 +1,72 @@
 package main
 
-import "sync"
+import (
+	"sync"
 
-type memberModel struct {
-	body map[int64]bool
-	arr  []string
+	"github.com/zboyco/bililive"
+)
+
+// drawModel 抽奖状态，web接口和弹幕回调会同时访问
+type drawModel struct {
+	live    *bililive.Live
+	roomID  int
+	keyword string
+	lottery *bililive.Lottery
+	sent    int // 已经返回给页面的参与者数量
 	sync.Mutex
 }
 
-func (m *memberModel) Reset() {
+func (m *drawModel) SetKeyword(keyword string) {
 	m.Lock()
 	defer m.Unlock()
-	m.body = make(map[int64]bool)
-	m.arr = make([]string, 0)
+	m.keyword = keyword
+}
+
+func (m *drawModel) Start() error {
+	m.Lock()
+	defer m.Unlock()
+	if m.lottery != nil {
+		m.lottery.Stop()
+	}
+	lottery, err := m.live.StartLottery(m.roomID, bililive.LotteryConfig{Keyword: m.keyword})
+	if err != nil {
+		return err
+	}
+	m.lottery = lottery
+	m.sent = 0
+	return nil
 }
 
-func (m *memberModel) Add(id int64, v string) {
+func (m *drawModel) Stop() {
 	m.Lock()
 	defer m.Unlock()
-	if _, ok := m.body[id]; !ok {
-		m.body[id] = true
-		m.arr = append(m.arr, v)
+	if m.lottery != nil {
+		m.lottery.Stop()
 	}
 }
 
-func (m *memberModel) Pick() []string {
+// Pick 返回上次之后新增的参与者
+func (m *drawModel) Pick() []string {
 	m.Lock()
 	defer m.Unlock()
-	arr := m.arr
-	m.arr = make([]string, 0)
+	arr := make([]string, 0)
+	if m.lottery == nil {
+		return arr
+	}
+	entrants := m.lottery.Entrants()
+	for _, entrant := range entrants[m.sent:] {
+		arr = append(arr, entrant.UserName)
+	}
+	m.sent = len(entrants)
 	return arr
 }
+
+func (m *drawModel) Draw(n int) (*bililive.LotteryResult, error) {
+	m.Lock()
+	lottery := m.lottery
+	m.Unlock()
+	if lottery == nil {
+		return nil, errNoLottery
+	}
+	return lottery.Draw(n)
+}
@@ -1,28 +1,34 @@
 package main
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
 
-func startWeb(m *memberModel, point *string, run *bool) {
+var errNoLottery = errors.New("还没有开始抽奖")
+
+func startWeb(m *drawModel) {
 	gin.SetMode(gin.ReleaseMode)
 	r := gin.Default()
 	r.Static("/static", "./public")
 	r.Static("/html", "./templates")
 	r.GET("/api/set", func(c *gin.Context) {
-		*point = c.Query("point")
+		m.SetKeyword(c.Query("point"))
 
 		c.JSON(http.StatusOK, nil)
 	})
 	r.GET("/api/start", func(c *gin.Context) {
-		*run = true
-		m.Reset()
+		if err := m.Start(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusOK, nil)
 	})
 	r.GET("/api/stop", func(c *gin.Context) {
-		*run = false
+		m.Stop()
 
 		c.JSON(http.StatusOK, nil)
 	})
@@ -34,6 +40,15 @@ func startWeb(m *memberModel, point *string, run *bool) {
 			"members": result,
 		})
 	})
+	r.GET("/api/draw", func(c *gin.Context) {
+		n, _ := strconv.Atoi(c.DefaultQuery("n", "1"))
+		result, err := m.Draw(n)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, result)
+	})
 
 	_ = r.Run()
 }
@@ -0,0 +1,225 @@
+package bililive
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Filter 弹幕过滤器，多个分析协程会同时调用，实现需要保证并发安全
+type Filter interface {
+	// Filter 返回true表示丢弃该弹幕，reason为丢弃原因
+	Filter(roomID int, m *MsgModel) (reason string, drop bool)
+}
+
+// FilterFunc 函数形式的过滤器
+type FilterFunc func(roomID int, m *MsgModel) (string, bool)
+
+// Filter 实现Filter接口
+func (f FilterFunc) Filter(roomID int, m *MsgModel) (string, bool) {
+	return f(roomID, m)
+}
+
+// FilterResult 过滤结果
+type FilterResult struct {
+	Filter Filter // 丢弃弹幕的过滤器
+	Reason string // 丢弃原因
+}
+
+// 过滤器链，全局过滤器之后执行房间过滤器
+type filterChain struct {
+	sync.RWMutex
+	global []Filter
+	rooms  map[int][]Filter
+}
+
+func newFilterChain(global []Filter) *filterChain {
+	return &filterChain{
+		global: global,
+		rooms:  make(map[int][]Filter),
+	}
+}
+
+func (c *filterChain) set(roomID int, filters []Filter) {
+	c.Lock()
+	defer c.Unlock()
+	if len(filters) == 0 {
+		delete(c.rooms, roomID)
+		return
+	}
+	c.rooms[roomID] = filters
+}
+
+func (c *filterChain) filter(roomID int, m *MsgModel) *FilterResult {
+	c.RLock()
+	room := c.rooms[roomID]
+	c.RUnlock()
+	for _, filters := range [][]Filter{c.global, room} {
+		for _, f := range filters {
+			if reason, drop := f.Filter(roomID, m); drop {
+				return &FilterResult{Filter: f, Reason: reason}
+			}
+		}
+	}
+	return nil
+}
+
+// SetFilters 设置房间的弹幕过滤器，在全局过滤器Filters之后执行，不传过滤器则清除
+func (live *Live) SetFilters(roomID int, filters ...Filter) {
+	live.filters.set(roomID, filters)
+}
+
+// KeywordFilter 屏蔽词过滤器，弹幕包含任一屏蔽词时丢弃
+// 弹幕经过Normalizer规范化时，屏蔽词也用同样的规则规范化后匹配，屏蔽词较多时使用KeywordMatcher
+type KeywordFilter struct {
+	Keywords []string
+}
+
+// Filter 实现Filter接口
+func (f *KeywordFilter) Filter(roomID int, m *MsgModel) (string, bool) {
+	text := m.Text()
+	for _, keyword := range f.Keywords {
+		if keyword != "" && strings.Contains(text, m.normalize(keyword)) {
+			return "包含屏蔽词：" + keyword, true
+		}
+	}
+	return "", false
+}
+
+// RegexpFilter 正则过滤器，原始内容或规范化后的内容匹配任一正则时丢弃
+// 正则不会被规范化，匹配规范化内容时注意其中的字母为小写、没有空白字符，可以使用(?i)忽略大小写
+type RegexpFilter struct {
+	Patterns []*regexp.Regexp
+}
+
+// NewRegexpFilter 创建正则过滤器
+func NewRegexpFilter(patterns ...string) (*RegexpFilter, error) {
+	f := &RegexpFilter{}
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		f.Patterns = append(f.Patterns, re)
+	}
+	return f, nil
+}
+
+// Filter 实现Filter接口
+func (f *RegexpFilter) Filter(roomID int, m *MsgModel) (string, bool) {
+	for _, re := range f.Patterns {
+		if re.MatchString(m.Content) || m.NormalizedContent != "" && re.MatchString(m.NormalizedContent) {
+			return "匹配屏蔽规则：" + re.String(), true
+		}
+	}
+	return "", false
+}
+
+// UserFilter 用户黑名单过滤器
+type UserFilter struct {
+	sync.RWMutex
+	userIDs map[int64]bool
+}
+
+// NewUserFilter 创建用户黑名单过滤器
+func NewUserFilter(userIDs ...int64) *UserFilter {
+	f := &UserFilter{userIDs: make(map[int64]bool)}
+	f.Add(userIDs...)
+	return f
+}
+
+// Add 添加用户
+func (f *UserFilter) Add(userIDs ...int64) {
+	f.Lock()
+	defer f.Unlock()
+	for _, id := range userIDs {
+		f.userIDs[id] = true
+	}
+}
+
+// Remove 移除用户
+func (f *UserFilter) Remove(userIDs ...int64) {
+	f.Lock()
+	defer f.Unlock()
+	for _, id := range userIDs {
+		delete(f.userIDs, id)
+	}
+}
+
+// Filter 实现Filter接口
+func (f *UserFilter) Filter(roomID int, m *MsgModel) (string, bool) {
+	f.RLock()
+	defer f.RUnlock()
+	if f.userIDs[m.UserID] {
+		return fmt.Sprintf("用户 %d 在黑名单中", m.UserID), true
+	}
+	return "", false
+}
+
+// LevelFilter 等级过滤器，用户等级或粉丝勋章等级不足时丢弃
+type LevelFilter struct {
+	MinUserLevel  int   // 最低用户等级，0不限制
+	MinMedalLevel int   // 最低粉丝勋章等级，0不限制
+	MedalRoomID   int64 // 非0时只认可该直播间的粉丝勋章
+}
+
+// Filter 实现Filter接口
+func (f *LevelFilter) Filter(roomID int, m *MsgModel) (string, bool) {
+	if m.UserLevel < f.MinUserLevel {
+		return fmt.Sprintf("用户等级 %d 低于 %d", m.UserLevel, f.MinUserLevel), true
+	}
+	if f.MinMedalLevel > 0 {
+		medalLevel := m.MedalLevel
+		if f.MedalRoomID != 0 && m.MedalRoomID != f.MedalRoomID {
+			medalLevel = 0
+		}
+		if medalLevel < f.MinMedalLevel {
+			return fmt.Sprintf("粉丝勋章等级 %d 低于 %d", medalLevel, f.MinMedalLevel), true
+		}
+	}
+	return "", false
+}
+
+// DuplicateFilter 重复弹幕过滤器，时间窗口内相同内容只保留第一条
+type DuplicateFilter struct {
+	sync.Mutex
+	window  time.Duration
+	perUser bool
+	seen    map[string]time.Time
+	cleaned time.Time
+}
+
+// NewDuplicateFilter 创建重复弹幕过滤器，perUser为true时只过滤同一用户的重复弹幕
+func NewDuplicateFilter(window time.Duration, perUser bool) *DuplicateFilter {
+	return &DuplicateFilter{
+		window:  window,
+		perUser: perUser,
+		seen:    make(map[string]time.Time),
+	}
+}
+
+// Filter 实现Filter接口
+func (f *DuplicateFilter) Filter(roomID int, m *MsgModel) (string, bool) {
+	key := fmt.Sprintf("%d:%s", roomID, m.Text())
+	if f.perUser {
+		key = fmt.Sprintf("%d:%d:%s", roomID, m.UserID, m.Text())
+	}
+	now := time.Now()
+	f.Lock()
+	defer f.Unlock()
+	if now.Sub(f.cleaned) > f.window {
+		for k, t := range f.seen {
+			if now.Sub(t) > f.window {
+				delete(f.seen, k)
+			}
+		}
+		f.cleaned = now
+	}
+	if t, ok := f.seen[key]; ok && now.Sub(t) <= f.window {
+		return "重复弹幕", true
+	}
+	f.seen[key] = now
+	return "", false
+}
@@ -0,0 +1,102 @@
+package bililive
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+)
+
+// FLV tag类型
+const (
+	flvTagAudio  = 8
+	flvTagVideo  = 9
+	flvTagScript = 18
+)
+
+const flvMaxTagSize = 16 << 20
+
+var errInvalidFLV = errors.New("不是有效的FLV数据")
+
+// FLV文件头，包含音频和视频，后面紧跟PreviousTagSize0
+var flvHeader = []byte{'F', 'L', 'V', 1, 5, 0, 0, 0, 9, 0, 0, 0, 0}
+
+// FLV中的一个tag
+type flvTag struct {
+	Type      byte
+	Timestamp int64 // 毫秒
+	Data      []byte
+}
+
+// 视频关键帧
+func (t *flvTag) isKeyframe() bool {
+	return t.Type == flvTagVideo && len(t.Data) > 0 && t.Data[0]>>4 == 1
+}
+
+// 音视频解码参数（AVC/HEVC sequence header、AAC sequence header）
+func (t *flvTag) isSequenceHeader() bool {
+	if len(t.Data) < 2 {
+		return false
+	}
+	switch t.Type {
+	case flvTagVideo:
+		codec := t.Data[0] & 0x0F
+		return (codec == 7 || codec == 12) && t.Data[1] == 0
+	case flvTagAudio:
+		return t.Data[0]>>4 == 10 && t.Data[1] == 0
+	}
+	return false
+}
+
+// 读取FLV文件头和PreviousTagSize0
+func readFLVHeader(r io.Reader) error {
+	header := make([]byte, 9)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return err
+	}
+	if header[0] != 'F' || header[1] != 'L' || header[2] != 'V' {
+		return errInvalidFLV
+	}
+	offset := binary.BigEndian.Uint32(header[5:9])
+	if offset < 9 {
+		return errInvalidFLV
+	}
+	// 跳过扩展的文件头和PreviousTagSize0
+	_, err := io.CopyN(ioutil.Discard, r, int64(offset)-9+4)
+	return err
+}
+
+// 读取一个tag和它后面的PreviousTagSize
+func readFLVTag(r io.Reader) (*flvTag, error) {
+	header := make([]byte, 11)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	size := int(header[1])<<16 | int(header[2])<<8 | int(header[3])
+	if size > flvMaxTagSize {
+		return nil, errInvalidFLV
+	}
+	tag := &flvTag{
+		Type:      header[0] & 0x1F,
+		Timestamp: int64(header[7])<<24 | int64(header[4])<<16 | int64(header[5])<<8 | int64(header[6]),
+		Data:      make([]byte, size+4),
+	}
+	if _, err := io.ReadFull(r, tag.Data); err != nil {
+		return nil, err
+	}
+	tag.Data = tag.Data[:size]
+	return tag, nil
+}
+
+// 写入一个tag和它后面的PreviousTagSize，返回写入的字节数
+func writeFLVTag(w io.Writer, tag *flvTag, timestamp int64) (int, error) {
+	size := len(tag.Data)
+	buf := make([]byte, 11, 11+size+4)
+	buf[0] = tag.Type
+	buf[1], buf[2], buf[3] = byte(size>>16), byte(size>>8), byte(size)
+	buf[4], buf[5], buf[6], buf[7] = byte(timestamp>>16), byte(timestamp>>8), byte(timestamp), byte(timestamp>>24)
+	buf = append(buf, tag.Data...)
+	buf = append(buf, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(buf[11+size:], uint32(11+size))
+	return w.Write(buf)
+}
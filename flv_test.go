@@ -0,0 +1,80 @@
+package bililive
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestFLVTagRoundTrip(t *testing.T) {
+	tags := []*flvTag{
+		{Type: flvTagScript, Timestamp: 0, Data: []byte("onMetaData")},
+		{Type: flvTagVideo, Timestamp: 0, Data: []byte{0x17, 0, 0, 0, 0, 1, 2}},
+		{Type: flvTagAudio, Timestamp: 0xABCDEF, Data: []byte{0xAF, 1, 3}},
+		// 超过24位的时间戳使用扩展字节
+		{Type: flvTagVideo, Timestamp: 0x12345678, Data: bytes.Repeat([]byte{0x27, 1}, 1000)},
+		{Type: flvTagVideo, Timestamp: 0x7FFFFFFF, Data: []byte{}},
+	}
+	buf := &bytes.Buffer{}
+	buf.Write(flvHeader)
+	for _, tag := range tags {
+		n, err := writeFLVTag(buf, tag, tag.Timestamp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != 11+len(tag.Data)+4 {
+			t.Fatalf("wrote %d bytes", n)
+		}
+	}
+	// 扩展字节为时间戳的高8位
+	offset := len(flvHeader)
+	for _, tag := range tags[:3] {
+		offset += 11 + len(tag.Data) + 4
+	}
+	if buf.Bytes()[offset+7] != 0x12 {
+		t.Fatal("extended timestamp byte not written")
+	}
+
+	if err := readFLVHeader(buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range tags {
+		got, err := readFLVTag(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("got type %d ts %#x len %d, want type %d ts %#x len %d",
+				got.Type, got.Timestamp, len(got.Data), want.Type, want.Timestamp, len(want.Data))
+		}
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("%d bytes left", buf.Len())
+	}
+}
+
+func TestFLVHeaderInvalid(t *testing.T) {
+	if err := readFLVHeader(bytes.NewReader([]byte("<html>body</html>"))); err != errInvalidFLV {
+		t.Fatal(err)
+	}
+}
+
+func TestFLVTagKinds(t *testing.T) {
+	cases := []struct {
+		tag       *flvTag
+		keyframe  bool
+		seqHeader bool
+	}{
+		{&flvTag{Type: flvTagVideo, Data: []byte{0x17, 0}}, true, true},
+		{&flvTag{Type: flvTagVideo, Data: []byte{0x17, 1}}, true, false},
+		{&flvTag{Type: flvTagVideo, Data: []byte{0x27, 1}}, false, false},
+		{&flvTag{Type: flvTagVideo, Data: []byte{0x1C, 0}}, true, true},
+		{&flvTag{Type: flvTagAudio, Data: []byte{0xAF, 0}}, false, true},
+		{&flvTag{Type: flvTagAudio, Data: []byte{0xAF, 1}}, false, false},
+	}
+	for i, c := range cases {
+		if c.tag.isKeyframe() != c.keyframe || c.tag.isSequenceHeader() != c.seqHeader {
+			t.Errorf("case %d", i)
+		}
+	}
+}
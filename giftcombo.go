@@ -0,0 +1,235 @@
+package bililive
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 默认礼物合并静默时间
+const defaultGiftComboWindow = 3 * time.Second
+
+// 检查静默时间的最短间隔
+const minGiftComboTick = 10 * time.Millisecond
+
+// giftComboAggregator 礼物连击合并
+// 同一用户连续点击礼物时b站会推送大量SEND_GIFT、COMBO_SEND、COMBO_END，
+// 这里按batch_combo_id（没有时按用户+礼物）合并，静默时间内没有新消息或收到COMBO_END后输出一次
+// 合并结果都在run协程中按结束的顺序输出
+type giftComboAggregator struct {
+	sync.Mutex
+	window  time.Duration
+	emit    func(int, *GiftComboModel)
+	entries map[string]*giftComboEntry // key: 房间+批量连击ID 或 房间+用户+礼物
+	ready   []*giftComboEntry          // 已经结束、等待输出的合并结果
+	notify  chan struct{}
+}
+
+type giftComboEntry struct {
+	roomID   int
+	keys     []string
+	combo    *GiftComboModel
+	lastTime time.Time
+}
+
+func newGiftComboAggregator(window time.Duration, emit func(int, *GiftComboModel)) *giftComboAggregator {
+	if window <= 0 {
+		window = defaultGiftComboWindow
+	}
+	return &giftComboAggregator{
+		window:  window,
+		emit:    emit,
+		entries: make(map[string]*giftComboEntry),
+		notify:  make(chan struct{}, 1),
+	}
+}
+
+func giftComboBatchKey(roomID int, batchComboID string) string {
+	if batchComboID == "" {
+		return ""
+	}
+	return fmt.Sprintf("%d:%s", roomID, batchComboID)
+}
+
+func giftComboUserKey(roomID int, userID int64, giftID int) string {
+	return fmt.Sprintf("%d:%d:%d", roomID, userID, giftID)
+}
+
+// 查找合并项，优先按批量连击ID
+func (a *giftComboAggregator) find(batchKey, userKey string) *giftComboEntry {
+	if batchKey != "" {
+		if entry, ok := a.entries[batchKey]; ok {
+			return entry
+		}
+	}
+	return a.entries[userKey]
+}
+
+// 记录礼物
+func (a *giftComboAggregator) addGift(roomID int, m *GiftModel) {
+	a.Lock()
+	giftID, giftName := m.GiftID, m.GiftName
+	if m.IsBlindGift() {
+		// 盲盒按用户购买的盲盒合并
+		giftID, giftName = m.BlindGift.OriginalGiftID, m.BlindGift.OriginalGiftName
+	}
+	batchKey := giftComboBatchKey(roomID, m.BatchComboID)
+	userKey := giftComboUserKey(roomID, m.UserID, giftID)
+	entry := a.find(batchKey, userKey)
+	if entry != nil && m.BatchComboID != "" && entry.combo.BatchComboID != "" && entry.combo.BatchComboID != m.BatchComboID {
+		// 同一用户开始了新的批量连击，先输出上一次的
+		a.finish(entry)
+		entry = nil
+	}
+	if entry == nil {
+		entry = &giftComboEntry{
+			roomID: roomID,
+			combo: &GiftComboModel{
+				BatchComboID: m.BatchComboID,
+				UserID:       m.UserID,
+				UserName:     m.UserName,
+				FaceURL:      m.FaceURL,
+				GuardLevel:   m.GuardLevel,
+				GiftID:       giftID,
+				GiftName:     giftName,
+				CoinType:     m.CoinType,
+				StartTime:    m.Timestamp,
+				Room:         m.Room,
+			},
+		}
+	}
+	a.bind(entry, batchKey)
+	a.bind(entry, userKey)
+	entry.combo.Num += m.Num
+	entry.combo.TotalCoin += m.PaidCoin()
+	entry.combo.Count++
+	entry.combo.EndTime = m.Timestamp
+	entry.lastTime = time.Now()
+	a.Unlock()
+}
+
+// 记录连击，仅用于延长静默时间
+func (a *giftComboAggregator) comboSend(roomID int, m *ComboSendModel) {
+	a.Lock()
+	defer a.Unlock()
+	entry := a.find(giftComboBatchKey(roomID, m.BatchComboID), giftComboUserKey(roomID, m.UserID, m.GiftID))
+	if entry == nil {
+		return
+	}
+	entry.lastTime = time.Now()
+}
+
+// 连击结束，校正数量后立即输出
+func (a *giftComboAggregator) comboEnd(roomID int, m *ComboEndModel) {
+	a.Lock()
+	entry := a.find(giftComboBatchKey(roomID, m.BatchComboID), giftComboUserKey(roomID, m.UserID, m.GiftID))
+	if entry == nil {
+		a.Unlock()
+		return
+	}
+	if num := m.ComboNum * m.GiftNum; num > entry.combo.Num {
+		entry.combo.Num = num
+	}
+	if m.ComboTotalCoin > entry.combo.TotalCoin {
+		entry.combo.TotalCoin = m.ComboTotalCoin
+	}
+	if m.EndTime > entry.combo.EndTime {
+		entry.combo.EndTime = m.EndTime
+	}
+	a.finish(entry)
+	a.Unlock()
+}
+
+// 清空房间
+func (a *giftComboAggregator) clear(roomID int) {
+	a.Lock()
+	defer a.Unlock()
+	for _, entry := range a.entries {
+		if entry.roomID == roomID {
+			a.unbind(entry)
+		}
+	}
+}
+
+// 合并结束，交给run协程输出，调用时需要加锁
+func (a *giftComboAggregator) finish(entry *giftComboEntry) {
+	a.unbind(entry)
+	a.ready = append(a.ready, entry)
+	select {
+	case a.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (a *giftComboAggregator) bind(entry *giftComboEntry, key string) {
+	if key == "" {
+		return
+	}
+	if _, ok := a.entries[key]; ok {
+		return
+	}
+	a.entries[key] = entry
+	entry.keys = append(entry.keys, key)
+}
+
+func (a *giftComboAggregator) unbind(entry *giftComboEntry) {
+	for _, key := range entry.keys {
+		if a.entries[key] == entry {
+			delete(a.entries, key)
+		}
+	}
+	entry.keys = nil
+}
+
+// 输出合并结果，定时检查超过静默时间的合并
+func (a *giftComboAggregator) run(ctx context.Context) {
+	interval := a.window / 2
+	if interval < minGiftComboTick {
+		interval = minGiftComboTick
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			a.expire(now)
+		case <-a.notify:
+		}
+		for _, entry := range a.take() {
+			a.emit(entry.roomID, entry.combo)
+		}
+	}
+}
+
+// 结束超过静默时间的合并
+func (a *giftComboAggregator) expire(now time.Time) {
+	a.Lock()
+	defer a.Unlock()
+	var expired []*giftComboEntry
+	for key, entry := range a.entries {
+		// 同一合并项有多个key，只在第一个key处记录
+		if entry.keys[0] != key || now.Sub(entry.lastTime) < a.window {
+			continue
+		}
+		expired = append(expired, entry)
+	}
+	sort.Slice(expired, func(i, j int) bool {
+		return expired[i].lastTime.Before(expired[j].lastTime)
+	})
+	for _, entry := range expired {
+		a.finish(entry)
+	}
+}
+
+// 取出等待输出的合并结果
+func (a *giftComboAggregator) take() []*giftComboEntry {
+	a.Lock()
+	defer a.Unlock()
+	ready := a.ready
+	a.ready = nil
+	return ready
+}
@@ -0,0 +1,119 @@
+package bililive
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type comboRecorder struct {
+	sync.Mutex
+	combos  []*GiftComboModel
+	running int32
+	overlap bool
+}
+
+func (r *comboRecorder) emit(roomID int, m *GiftComboModel) {
+	if atomic.AddInt32(&r.running, 1) > 1 {
+		r.overlap = true
+	}
+	time.Sleep(time.Millisecond)
+	r.Lock()
+	r.combos = append(r.combos, m)
+	r.Unlock()
+	atomic.AddInt32(&r.running, -1)
+}
+
+func (r *comboRecorder) wait(t *testing.T, n int) []*GiftComboModel {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		r.Lock()
+		combos := r.combos
+		r.Unlock()
+		if len(combos) >= n {
+			return combos
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("%d combos, want %d", len(r.combos), n)
+	return nil
+}
+
+func TestGiftComboMerge(t *testing.T) {
+	r := &comboRecorder{}
+	a := newGiftComboAggregator(time.Hour, r.emit)
+	gift := func(batch string, num int) {
+		a.addGift(1, &GiftModel{BatchComboID: batch, UserID: 10, UserName: "用户", GiftID: 1, GiftName: "辣条", Num: num, Price: 100})
+	}
+	gift("batch:1", 1)
+	gift("batch:1", 2)
+	a.comboSend(1, &ComboSendModel{BatchComboID: "batch:1", UserID: 10, GiftID: 1})
+	// 同一用户的新批量连击，先结束上一次的
+	gift("batch:2", 5)
+	// COMBO_END校正数量后立即结束
+	a.comboEnd(1, &ComboEndModel{BatchComboID: "batch:2", UserID: 10, GiftID: 1, ComboNum: 3, GiftNum: 5, ComboTotalCoin: 1500})
+	// 没有批量连击ID时按用户和礼物合并
+	a.addGift(2, &GiftModel{UserID: 20, GiftID: 2, Num: 1, Price: 1000})
+	a.addGift(2, &GiftModel{UserID: 20, GiftID: 2, Num: 1, Price: 1000})
+	a.addGift(2, &GiftModel{UserID: 20, GiftID: 3, Num: 1, Price: 1000})
+
+	// 只在run协程中输出
+	time.Sleep(20 * time.Millisecond)
+	if len(r.combos) != 0 {
+		t.Fatal("emitted on the caller goroutine")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go a.run(ctx)
+	combos := r.wait(t, 2)
+	if combos[0].BatchComboID != "batch:1" || combos[0].Num != 3 || combos[0].Count != 2 || combos[0].TotalCoin != 300 {
+		t.Fatalf("first %+v", combos[0])
+	}
+	if combos[1].BatchComboID != "batch:2" || combos[1].Num != 15 || combos[1].Count != 1 || combos[1].TotalCoin != 1500 {
+		t.Fatalf("second %+v", combos[1])
+	}
+
+	// 静默时间到期
+	now := time.Now().Add(time.Hour)
+	a.expire(now)
+	a.notify <- struct{}{}
+	combos = r.wait(t, 4)
+	if combos[2].GiftID != 2 || combos[2].Num != 2 || combos[2].Count != 2 || combos[3].GiftID != 3 {
+		t.Fatalf("expired %+v %+v", combos[2], combos[3])
+	}
+	a.Lock()
+	left := len(a.entries)
+	a.Unlock()
+	if left != 0 {
+		t.Fatalf("%d entries left", left)
+	}
+}
+
+func TestGiftComboTinyWindow(t *testing.T) {
+	r := &comboRecorder{}
+	a := newGiftComboAggregator(time.Nanosecond, r.emit)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go a.run(ctx)
+
+	// 分析协程和run协程同时结束合并，GiftCombo不会并发调用
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(userID int64) {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				a.addGift(1, &GiftModel{UserID: userID, GiftID: j % 2, Num: 1})
+				a.comboEnd(1, &ComboEndModel{UserID: userID, GiftID: j % 2, ComboNum: 1, GiftNum: 1})
+			}
+		}(int64(i))
+	}
+	wg.Wait()
+	r.wait(t, 80)
+	if r.overlap {
+		t.Fatal("GiftCombo called concurrently")
+	}
+}
@@ -0,0 +1,219 @@
+package bililive
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// 收益类别
+const (
+	RevenueGift      = "gift"       // 礼物
+	RevenueGuard     = "guard"      // 上船
+	RevenueSuperChat = "super_chat" // 醒目留言
+)
+
+// RevenueSummary 一场直播的收益汇总
+type RevenueSummary struct {
+	RoomID     int              // 房间ID
+	StartTime  int64            // 开始时间
+	EndTime    int64            // 结束时间，直播未结束时为0
+	Total      Value            // 付费总价值
+	Free       Value            // 免费礼物总价值
+	ByCategory map[string]Value // 按类别统计
+	ByGift     []*GiftRevenue   // 按礼物统计，按价值从高到低排序
+	ByUser     []*UserRevenue   // 按用户统计，按价值从高到低排序
+}
+
+// GiftRevenue 礼物收益
+type GiftRevenue struct {
+	GiftID   int    // 礼物ID，盲盒为盲盒礼物ID
+	GiftName string // 礼物名称
+	Num      int    // 数量
+	Value    Value  // 价值
+}
+
+// UserRevenue 用户贡献
+type UserRevenue struct {
+	UserID     int64            // 用户ID
+	UserName   string           // 用户名称
+	Value      Value            // 付费总价值
+	ByCategory map[string]Value // 按类别统计
+}
+
+// revenueLedger 收益账本，按房间记录当前直播的收益
+type revenueLedger struct {
+	sync.Mutex
+	sessions map[int]*revenueSession
+}
+
+// revenueSession 一场直播的收益记录
+type revenueSession struct {
+	roomID     int
+	startTime  int64
+	total      Value
+	free       Value
+	byCategory map[string]Value
+	byGift     map[int]*GiftRevenue
+	byUser     map[int64]*UserRevenue
+}
+
+func newRevenueLedger() *revenueLedger {
+	return &revenueLedger{
+		sessions: make(map[int]*revenueSession),
+	}
+}
+
+// 开始新的直播，丢弃之前未结束的记录
+func (l *revenueLedger) open(roomID int) {
+	l.Lock()
+	defer l.Unlock()
+	l.sessions[roomID] = newRevenueSession(roomID)
+}
+
+// 结束直播，返回汇总；没有进行中的直播时返回nil
+func (l *revenueLedger) close(roomID int) *RevenueSummary {
+	l.Lock()
+	defer l.Unlock()
+	session, ok := l.sessions[roomID]
+	if !ok {
+		return nil
+	}
+	delete(l.sessions, roomID)
+	return session.snapshot(time.Now().Unix())
+}
+
+// 移除房间
+func (l *revenueLedger) clear(roomID int) {
+	l.Lock()
+	defer l.Unlock()
+	delete(l.sessions, roomID)
+}
+
+// 获取当前直播的快照
+func (l *revenueLedger) snapshot(roomID int) (*RevenueSummary, bool) {
+	l.Lock()
+	defer l.Unlock()
+	session, ok := l.sessions[roomID]
+	if !ok {
+		return nil, false
+	}
+	return session.snapshot(0), true
+}
+
+// 获取房间记录，加入房间时直播可能已经开始，没有记录时自动开始
+func (l *revenueLedger) session(roomID int) *revenueSession {
+	session, ok := l.sessions[roomID]
+	if !ok {
+		session = newRevenueSession(roomID)
+		l.sessions[roomID] = session
+	}
+	return session
+}
+
+func (l *revenueLedger) addGift(roomID int, m *GiftModel) {
+	l.Lock()
+	defer l.Unlock()
+	session := l.session(roomID)
+	giftID, giftName := m.GiftID, m.GiftName
+	if m.IsBlindGift() {
+		giftID, giftName = m.BlindGift.OriginalGiftID, m.BlindGift.OriginalGiftName
+	}
+	v := m.Value()
+	gift, ok := session.byGift[giftID]
+	if !ok {
+		gift = &GiftRevenue{GiftID: giftID, GiftName: giftName}
+		session.byGift[giftID] = gift
+	}
+	gift.Num += m.Num
+	gift.Value = gift.Value.Add(v)
+	session.add(RevenueGift, m.UserID, m.UserName, v)
+}
+
+func (l *revenueLedger) addGuard(roomID int, m *GuardBuyModel) {
+	l.Lock()
+	defer l.Unlock()
+	l.session(roomID).add(RevenueGuard, m.UserID, m.UserName, m.Value())
+}
+
+func (l *revenueLedger) addSuperChat(roomID int, m *SuperChatMessageModel) {
+	l.Lock()
+	defer l.Unlock()
+	l.session(roomID).add(RevenueSuperChat, m.UserID, m.UserInfo.UserName, m.Value())
+}
+
+func newRevenueSession(roomID int) *revenueSession {
+	return &revenueSession{
+		roomID:     roomID,
+		startTime:  time.Now().Unix(),
+		byCategory: make(map[string]Value),
+		byGift:     make(map[int]*GiftRevenue),
+		byUser:     make(map[int64]*UserRevenue),
+	}
+}
+
+func (s *revenueSession) add(category string, userID int64, userName string, v Value) {
+	if !v.Paid {
+		s.free = s.free.Add(v)
+		return
+	}
+	s.total = s.total.Add(v)
+	s.byCategory[category] = s.byCategory[category].Add(v)
+	user, ok := s.byUser[userID]
+	if !ok {
+		user = &UserRevenue{UserID: userID, ByCategory: make(map[string]Value)}
+		s.byUser[userID] = user
+	}
+	if userName != "" {
+		user.UserName = userName
+	}
+	user.Value = user.Value.Add(v)
+	user.ByCategory[category] = user.ByCategory[category].Add(v)
+}
+
+// 生成汇总，复制一份避免回调中读取时被修改
+func (s *revenueSession) snapshot(endTime int64) *RevenueSummary {
+	result := &RevenueSummary{
+		RoomID:     s.roomID,
+		StartTime:  s.startTime,
+		EndTime:    endTime,
+		Total:      s.total,
+		Free:       s.free,
+		ByCategory: make(map[string]Value, len(s.byCategory)),
+		ByGift:     make([]*GiftRevenue, 0, len(s.byGift)),
+		ByUser:     make([]*UserRevenue, 0, len(s.byUser)),
+	}
+	for k, v := range s.byCategory {
+		result.ByCategory[k] = v
+	}
+	for _, gift := range s.byGift {
+		g := *gift
+		result.ByGift = append(result.ByGift, &g)
+	}
+	for _, user := range s.byUser {
+		u := *user
+		u.ByCategory = make(map[string]Value, len(user.ByCategory))
+		for k, v := range user.ByCategory {
+			u.ByCategory[k] = v
+		}
+		result.ByUser = append(result.ByUser, &u)
+	}
+	sort.Slice(result.ByGift, func(i, j int) bool {
+		if result.ByGift[i].Value.Paid != result.ByGift[j].Value.Paid {
+			return result.ByGift[i].Value.Paid
+		}
+		return result.ByGift[i].Value.Coin > result.ByGift[j].Value.Coin
+	})
+	sort.Slice(result.ByUser, func(i, j int) bool {
+		return result.ByUser[i].Value.Coin > result.ByUser[j].Value.Coin
+	})
+	return result
+}
+
+// Revenue 获取房间当前直播的收益快照，需要开启RevenueLedger
+func (live *Live) Revenue(roomID int) (*RevenueSummary, bool) {
+	if live.ledger == nil {
+		return nil, false
+	}
+	return live.ledger.snapshot(roomID)
+}
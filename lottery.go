@@ -0,0 +1,304 @@
+package bililive
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LotteryConfig 抽奖配置
+type LotteryConfig struct {
+	Keyword       string // 参与口令，弹幕内容与口令相同时参与
+	GiftID        int    // 需要在抽奖期间赠送的礼物ID，0不需要
+	RequireGuard  bool   // 需要是舰长，或在抽奖期间上船
+	MinMedalLevel int    // 最低粉丝勋章等级，0不限制
+	MedalRoomID   int64  // 粉丝勋章所属直播间（真实房间ID），0为抽奖房间
+}
+
+// LotteryEntrant 抽奖参与者
+type LotteryEntrant struct {
+	UserID   int64     `json:"uid"`
+	UserName string    `json:"uname"`
+	Time     time.Time `json:"time"`
+}
+
+// LotteryResult 抽奖结果，使用相同的参与者和种子可以复现，Entrants为本次可以抽取的参与者（不含之前已中奖的用户）
+type LotteryResult struct {
+	RoomID   int               `json:"room_id"`
+	Keyword  string            `json:"keyword"`
+	Seed     int64             `json:"seed"`
+	Entrants []*LotteryEntrant `json:"entrants"`
+	Winners  []*LotteryEntrant `json:"winners"`
+	DrawTime time.Time         `json:"draw_time"`
+}
+
+// WriteCSV 导出抽奖结果，可以用于核对
+// 前两行为房间ID、口令、种子、参与人数和开奖时间，之后按抽取时的顺序列出全部参与者和中奖序号
+// 用rand.New(rand.NewSource(种子)).Perm(参与人数)的前几个下标取参与者可以复现中奖名单
+func (r *LotteryResult) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	_ = writer.Write([]string{"房间ID", "口令", "种子", "参与人数", "开奖时间"})
+	_ = writer.Write([]string{
+		strconv.Itoa(r.RoomID),
+		r.Keyword,
+		strconv.FormatInt(r.Seed, 10),
+		strconv.Itoa(len(r.Entrants)),
+		r.DrawTime.Format("2006-01-02 15:04:05"),
+	})
+	won := make(map[*LotteryEntrant]int, len(r.Winners))
+	for i, winner := range r.Winners {
+		won[winner] = i + 1
+	}
+	_ = writer.Write([]string{"序号", "用户ID", "用户名", "参与时间", "中奖序号"})
+	for i, entrant := range r.Entrants {
+		rank := ""
+		if n, ok := won[entrant]; ok {
+			rank = strconv.Itoa(n)
+		}
+		_ = writer.Write([]string{
+			strconv.Itoa(i + 1),
+			strconv.FormatInt(entrant.UserID, 10),
+			entrant.UserName,
+			entrant.Time.Format("2006-01-02 15:04:05"),
+			rank,
+		})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// Lottery 口令抽奖
+type Lottery struct {
+	RoomID    int           // 房间ID
+	Config    LotteryConfig // 配置
+	StartTime time.Time     // 开始时间
+
+	sync.Mutex
+	running  bool
+	entrants []*LotteryEntrant
+	joined   map[int64]bool
+	pending  map[int64]*LotteryEntrant // 发了口令但还未满足礼物/上船条件的用户
+	gifted   map[int64]bool
+	guarded  map[int64]bool
+	results  []*LotteryResult
+}
+
+func newLottery(roomID int, config LotteryConfig) *Lottery {
+	return &Lottery{
+		RoomID:    roomID,
+		Config:    config,
+		StartTime: time.Now(),
+		running:   true,
+		joined:    make(map[int64]bool),
+		pending:   make(map[int64]*LotteryEntrant),
+		gifted:    make(map[int64]bool),
+		guarded:   make(map[int64]bool),
+	}
+}
+
+// Running 是否正在收集参与者
+func (l *Lottery) Running() bool {
+	l.Lock()
+	defer l.Unlock()
+	return l.running
+}
+
+// Stop 停止收集参与者
+func (l *Lottery) Stop() {
+	l.Lock()
+	defer l.Unlock()
+	l.running = false
+}
+
+// Entrants 参与者列表，按参与顺序
+func (l *Lottery) Entrants() []*LotteryEntrant {
+	l.Lock()
+	defer l.Unlock()
+	result := make([]*LotteryEntrant, len(l.entrants))
+	copy(result, l.entrants)
+	return result
+}
+
+// Results 历次开奖结果
+func (l *Lottery) Results() []*LotteryResult {
+	l.Lock()
+	defer l.Unlock()
+	result := make([]*LotteryResult, len(l.results))
+	copy(result, l.results)
+	return result
+}
+
+// Draw 开奖，使用当前时间作为种子
+func (l *Lottery) Draw(n int) (*LotteryResult, error) {
+	return l.DrawWithSeed(n, time.Now().UnixNano())
+}
+
+// DrawWithSeed 使用指定种子开奖，已中奖的用户不会重复中奖
+func (l *Lottery) DrawWithSeed(n int, seed int64) (*LotteryResult, error) {
+	if n <= 0 {
+		return nil, errors.New("中奖人数必须大于0")
+	}
+	l.Lock()
+	defer l.Unlock()
+	won := make(map[int64]bool)
+	for _, result := range l.results {
+		for _, winner := range result.Winners {
+			won[winner.UserID] = true
+		}
+	}
+	candidates := make([]*LotteryEntrant, 0, len(l.entrants))
+	for _, entrant := range l.entrants {
+		if !won[entrant.UserID] {
+			candidates = append(candidates, entrant)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, errors.New("没有可抽取的参与者")
+	}
+	if n > len(candidates) {
+		n = len(candidates)
+	}
+	perm := rand.New(rand.NewSource(seed)).Perm(len(candidates))
+	result := &LotteryResult{
+		RoomID:   l.RoomID,
+		Keyword:  l.Config.Keyword,
+		Seed:     seed,
+		Entrants: candidates,
+		Winners:  make([]*LotteryEntrant, 0, n),
+		DrawTime: time.Now(),
+	}
+	for _, i := range perm[:n] {
+		result.Winners = append(result.Winners, candidates[i])
+	}
+	l.results = append(l.results, result)
+	return result, nil
+}
+
+// 收到弹幕
+func (l *Lottery) onMsg(m *MsgModel) {
+	if strings.TrimSpace(m.Content) != l.Config.Keyword {
+		return
+	}
+	if l.Config.MinMedalLevel > 0 {
+		medalRoomID := l.Config.MedalRoomID
+		if medalRoomID == 0 {
+			medalRoomID = int64(l.RoomID)
+			if m.Room != nil {
+				medalRoomID = int64(m.Room.RealRoomID)
+			}
+		}
+		if m.MedalRoomID != medalRoomID || m.MedalLevel < l.Config.MinMedalLevel {
+			return
+		}
+	}
+	l.Lock()
+	defer l.Unlock()
+	if !l.running || l.joined[m.UserID] {
+		return
+	}
+	if m.GuardLevel > 0 {
+		l.guarded[m.UserID] = true
+	}
+	if _, ok := l.pending[m.UserID]; !ok {
+		l.pending[m.UserID] = &LotteryEntrant{UserID: m.UserID, UserName: m.UserName, Time: time.Now()}
+	}
+	l.qualify(m.UserID)
+}
+
+// 收到礼物
+func (l *Lottery) onGift(m *GiftModel) {
+	if l.Config.GiftID == 0 || (m.GiftID != l.Config.GiftID && !(m.IsBlindGift() && m.BlindGift.OriginalGiftID == l.Config.GiftID)) {
+		return
+	}
+	l.Lock()
+	defer l.Unlock()
+	if !l.running {
+		return
+	}
+	l.gifted[m.UserID] = true
+	l.qualify(m.UserID)
+}
+
+// 上船
+func (l *Lottery) onGuard(m *GuardBuyModel) {
+	if !l.Config.RequireGuard {
+		return
+	}
+	l.Lock()
+	defer l.Unlock()
+	if !l.running {
+		return
+	}
+	l.guarded[m.UserID] = true
+	l.qualify(m.UserID)
+}
+
+// 检查是否满足条件，调用时需要加锁
+func (l *Lottery) qualify(userID int64) {
+	entrant, ok := l.pending[userID]
+	if !ok {
+		return
+	}
+	if l.Config.GiftID > 0 && !l.gifted[userID] {
+		return
+	}
+	if l.Config.RequireGuard && !l.guarded[userID] {
+		return
+	}
+	delete(l.pending, userID)
+	l.joined[userID] = true
+	l.entrants = append(l.entrants, entrant)
+}
+
+// 抽奖管理
+type lotteryManager struct {
+	sync.Mutex
+	rooms map[int]*Lottery
+}
+
+func newLotteryManager() *lotteryManager {
+	return &lotteryManager{
+		rooms: make(map[int]*Lottery),
+	}
+}
+
+func (lm *lotteryManager) get(roomID int) *Lottery {
+	lm.Lock()
+	defer lm.Unlock()
+	return lm.rooms[roomID]
+}
+
+func (lm *lotteryManager) clear(roomID int) {
+	lm.Lock()
+	defer lm.Unlock()
+	if l, ok := lm.rooms[roomID]; ok {
+		l.Stop()
+		delete(lm.rooms, roomID)
+	}
+}
+
+// StartLottery 在房间开始口令抽奖，房间已有进行中的抽奖时返回错误
+func (live *Live) StartLottery(roomID int, config LotteryConfig) (*Lottery, error) {
+	if config.Keyword == "" {
+		return nil, errors.New("抽奖口令不能为空")
+	}
+	live.lotteries.Lock()
+	defer live.lotteries.Unlock()
+	if l, ok := live.lotteries.rooms[roomID]; ok && l.Running() {
+		return nil, fmt.Errorf("房间 %d 已有进行中的抽奖", roomID)
+	}
+	l := newLottery(roomID, config)
+	live.lotteries.rooms[roomID] = l
+	return l, nil
+}
+
+// Lottery 获取房间最近一次抽奖
+func (live *Live) Lottery(roomID int) *Lottery {
+	return live.lotteries.get(roomID)
+}
@@ -0,0 +1,70 @@
+package bililive
+
+import (
+	"bytes"
+	"encoding/csv"
+	"math/rand"
+	"strconv"
+	"testing"
+)
+
+func TestLotteryCSV(t *testing.T) {
+	l := newLottery(1000, LotteryConfig{Keyword: "抽奖"})
+	for i := 1; i <= 20; i++ {
+		l.onMsg(&MsgModel{UserID: int64(i), UserName: "用户" + strconv.Itoa(i), Content: "抽奖"})
+	}
+	l.onMsg(&MsgModel{UserID: 1, Content: "抽奖"})
+	l.onMsg(&MsgModel{UserID: 99, Content: "其他"})
+	first, err := l.DrawWithSeed(3, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := l.DrawWithSeed(5, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Entrants) != 17 {
+		t.Fatalf("%d entrants", len(result.Entrants))
+	}
+
+	buf := &bytes.Buffer{}
+	if err := result.WriteCSV(buf); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3+17 {
+		t.Fatalf("%d records", len(records))
+	}
+	if records[1][0] != "1000" || records[1][1] != "抽奖" || records[1][2] != "7" || records[1][3] != "17" {
+		t.Fatalf("summary %v", records[1])
+	}
+
+	// 从导出的种子和参与者复现中奖名单
+	seed, _ := strconv.ParseInt(records[1][2], 10, 64)
+	count, _ := strconv.Atoi(records[1][3])
+	entrants := records[3:]
+	perm := rand.New(rand.NewSource(seed)).Perm(count)
+	for rank, i := range perm[:len(result.Winners)] {
+		row := entrants[i]
+		if row[1] != strconv.FormatInt(result.Winners[rank].UserID, 10) || row[4] != strconv.Itoa(rank+1) {
+			t.Fatalf("winner %d: %v", rank+1, row)
+		}
+	}
+	winners := 0
+	for _, row := range entrants {
+		if row[4] != "" {
+			winners++
+		}
+		for _, w := range first.Winners {
+			if row[1] == strconv.FormatInt(w.UserID, 10) {
+				t.Fatalf("previous winner %v listed", row)
+			}
+		}
+	}
+	if winners != 5 {
+		t.Fatalf("%d winners", winners)
+	}
+}
@@ -0,0 +1,235 @@
+package bililive
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// KeywordMatch 关键词命中
+type KeywordMatch struct {
+	Keyword string // 命中的关键词
+	Start   int    // 起始位置（字节）
+	End     int    // 结束位置（字节，不含）
+}
+
+// KeywordMatcher 基于Aho-Corasick的多关键词匹配，适合大量屏蔽词
+// 可以在运行中调用Build重建，不影响正在匹配的协程
+type KeywordMatcher struct {
+	automaton atomic.Value // *acAutomaton
+}
+
+// NewKeywordMatcher 创建关键词匹配
+func NewKeywordMatcher(keywords ...string) *KeywordMatcher {
+	m := &KeywordMatcher{}
+	m.Build(keywords)
+	return m
+}
+
+// Build 重建关键词，空关键词和重复关键词会被忽略
+func (m *KeywordMatcher) Build(keywords []string) {
+	m.automaton.Store(newACAutomaton(keywords))
+}
+
+// Keywords 当前的关键词
+func (m *KeywordMatcher) Keywords() []string {
+	a := m.load()
+	result := make([]string, len(a.keywords))
+	copy(result, a.keywords)
+	return result
+}
+
+// FindAll 查找全部命中
+func (m *KeywordMatcher) FindAll(text string) []KeywordMatch {
+	var result []KeywordMatch
+	m.load().scan(text, func(match KeywordMatch) bool {
+		result = append(result, match)
+		return true
+	})
+	return result
+}
+
+// Find 查找第一个命中
+func (m *KeywordMatcher) Find(text string) (KeywordMatch, bool) {
+	var result KeywordMatch
+	found := false
+	m.load().scan(text, func(match KeywordMatch) bool {
+		result = match
+		found = true
+		return false
+	})
+	return result, found
+}
+
+// Filter 实现Filter接口，弹幕命中任一关键词时丢弃
+// 弹幕经过Normalizer规范化时，关键词也用同样的规则规范化后匹配
+func (m *KeywordMatcher) Filter(roomID int, msg *MsgModel) (string, bool) {
+	a := m.load()
+	if msg.normalizer != nil && msg.NormalizedContent != "" {
+		a = a.normalized(msg.normalizer)
+	}
+	var keywords []string
+	seen := make(map[string]bool)
+	a.scan(msg.Text(), func(match KeywordMatch) bool {
+		if !seen[match.Keyword] {
+			seen[match.Keyword] = true
+			keywords = append(keywords, a.source(match.Keyword))
+		}
+		return true
+	})
+	if len(keywords) == 0 {
+		return "", false
+	}
+	return "包含屏蔽词：" + strings.Join(keywords, ","), true
+}
+
+func (m *KeywordMatcher) load() *acAutomaton {
+	a, ok := m.automaton.Load().(*acAutomaton)
+	if !ok {
+		return newACAutomaton(nil)
+	}
+	return a
+}
+
+// acAutomaton Aho-Corasick自动机，按字节匹配，UTF-8关键词只会在字符边界命中
+type acAutomaton struct {
+	keywords []string
+	sources  map[string]string // 规范化后的关键词 -> 原文，没有规范化时为nil
+	nodes    []acNode
+
+	normalizedLock sync.Mutex
+	normalizedAC   map[*Normalizer]*acAutomaton // 规范化关键词后的自动机，第一次使用时创建
+}
+
+type acNode struct {
+	next   map[byte]int32
+	fail   int32
+	output int32 // 以该节点结尾的关键词下标，-1为无
+	dict   int32 // 沿失败指针最近的有输出的节点，-1为无
+}
+
+func newACAutomaton(keywords []string) *acAutomaton {
+	return buildACAutomaton(keywords, nil)
+}
+
+// 关键词的原文
+func (a *acAutomaton) source(keyword string) string {
+	if source, ok := a.sources[keyword]; ok {
+		return source
+	}
+	return keyword
+}
+
+// 用规范化后的关键词创建自动机，命中时可以从sources找到原文
+func (a *acAutomaton) normalized(n *Normalizer) *acAutomaton {
+	a.normalizedLock.Lock()
+	defer a.normalizedLock.Unlock()
+	if result, ok := a.normalizedAC[n]; ok {
+		return result
+	}
+	if a.normalizedAC == nil {
+		a.normalizedAC = make(map[*Normalizer]*acAutomaton)
+	}
+	result := buildACAutomaton(a.keywords, n)
+	a.normalizedAC[n] = result
+	return result
+}
+
+func buildACAutomaton(sources []string, n *Normalizer) *acAutomaton {
+	a := &acAutomaton{
+		nodes: []acNode{{next: make(map[byte]int32), output: -1, dict: -1}},
+	}
+	if n != nil {
+		a.sources = make(map[string]string)
+	}
+	for _, source := range sources {
+		keyword := source
+		if n != nil {
+			keyword = n.Normalize(source)
+		}
+		if keyword == "" {
+			continue
+		}
+		var state int32
+		for i := 0; i < len(keyword); i++ {
+			next, ok := a.nodes[state].next[keyword[i]]
+			if !ok {
+				next = int32(len(a.nodes))
+				a.nodes = append(a.nodes, acNode{next: make(map[byte]int32), output: -1, dict: -1})
+				a.nodes[state].next[keyword[i]] = next
+			}
+			state = next
+		}
+		if a.nodes[state].output < 0 {
+			a.nodes[state].output = int32(len(a.keywords))
+			a.keywords = append(a.keywords, keyword)
+			if n != nil {
+				a.sources[keyword] = source
+			}
+		}
+	}
+
+	// 广度优先计算失败指针
+	queue := make([]int32, 0, len(a.nodes))
+	for _, child := range a.nodes[0].next {
+		queue = append(queue, child)
+	}
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+		for c, child := range a.nodes[state].next {
+			queue = append(queue, child)
+			fail := a.nodes[state].fail
+			for {
+				if next, ok := a.nodes[fail].next[c]; ok && next != child {
+					a.nodes[child].fail = next
+					break
+				}
+				if fail == 0 {
+					a.nodes[child].fail = 0
+					break
+				}
+				fail = a.nodes[fail].fail
+			}
+			failNode := &a.nodes[a.nodes[child].fail]
+			if failNode.output >= 0 {
+				a.nodes[child].dict = a.nodes[child].fail
+			} else {
+				a.nodes[child].dict = failNode.dict
+			}
+		}
+	}
+	return a
+}
+
+// 扫描文本，fn返回false时停止
+func (a *acAutomaton) scan(text string, fn func(KeywordMatch) bool) {
+	if len(a.keywords) == 0 {
+		return
+	}
+	var state int32
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		for {
+			if next, ok := a.nodes[state].next[c]; ok {
+				state = next
+				break
+			}
+			if state == 0 {
+				break
+			}
+			state = a.nodes[state].fail
+		}
+		for out := state; out >= 0; out = a.nodes[out].dict {
+			if idx := a.nodes[out].output; idx >= 0 {
+				keyword := a.keywords[idx]
+				if !fn(KeywordMatch{Keyword: keyword, Start: i + 1 - len(keyword), End: i + 1}) {
+					return
+				}
+			}
+			if out == 0 {
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package bililive
+
+import (
+	"math/rand"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestKeywordMatcherFindAll(t *testing.T) {
+	cases := []struct {
+		name     string
+		keywords []string
+		text     string
+		want     []KeywordMatch
+	}{
+		{"overlap", []string{"he", "she", "his", "hers"}, "ushers", []KeywordMatch{
+			{"she", 1, 4}, {"he", 2, 4}, {"hers", 2, 6},
+		}},
+		{"repeat", []string{"aa"}, "aaaa", []KeywordMatch{
+			{"aa", 0, 2}, {"aa", 1, 3}, {"aa", 2, 4},
+		}},
+		{"suffix", []string{"c", "abc", "bc"}, "xabcx", []KeywordMatch{
+			{"abc", 1, 4}, {"bc", 2, 4}, {"c", 3, 4},
+		}},
+		{"failure", []string{"abcd", "bce"}, "abce", []KeywordMatch{
+			{"bce", 1, 4},
+		}},
+		{"multibyte", []string{"主播", "播放", "直播"}, "直播主播放歌", []KeywordMatch{
+			{"直播", 0, 6}, {"主播", 6, 12}, {"播放", 9, 15},
+		}},
+		{"duplicate", []string{"abc", "", "abc"}, "abcabc", []KeywordMatch{
+			{"abc", 0, 3}, {"abc", 3, 6},
+		}},
+		{"none", []string{"广告"}, "正常弹幕", nil},
+		{"empty", nil, "abc", nil},
+	}
+	for _, c := range cases {
+		m := NewKeywordMatcher(c.keywords...)
+		if got := m.FindAll(c.text); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: FindAll = %v, want %v", c.name, got, c.want)
+		}
+		for _, match := range c.want {
+			if c.text[match.Start:match.End] != match.Keyword {
+				t.Errorf("%s: bad case %v", c.name, match)
+			}
+		}
+		first, ok := m.Find(c.text)
+		if ok != (len(c.want) > 0) || ok && first != c.want[0] {
+			t.Errorf("%s: Find = %v %v", c.name, first, ok)
+		}
+	}
+
+	if got := NewKeywordMatcher("abc", "", "abc", "ab").Keywords(); !reflect.DeepEqual(got, []string{"abc", "ab"}) {
+		t.Errorf("Keywords = %v", got)
+	}
+	if reason, drop := NewKeywordMatcher("he", "she").Filter(1, &MsgModel{Content: "she"}); !drop || reason != "包含屏蔽词：she,he" {
+		t.Errorf("Filter = %q %v", reason, drop)
+	}
+}
+
+// 与逐个关键词查找的结果比较
+func TestKeywordMatcherBruteForce(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	alphabet := []string{"a", "b", "c", "播"}
+	random := func(n int) string {
+		var b strings.Builder
+		for i := 0; i < n; i++ {
+			b.WriteString(alphabet[r.Intn(len(alphabet))])
+		}
+		return b.String()
+	}
+	for round := 0; round < 200; round++ {
+		keywords := make([]string, 1+r.Intn(8))
+		for i := range keywords {
+			keywords[i] = random(1 + r.Intn(4))
+		}
+		text := random(r.Intn(30))
+
+		var want []KeywordMatch
+		seen := make(map[string]bool)
+		for _, keyword := range keywords {
+			if seen[keyword] {
+				continue
+			}
+			seen[keyword] = true
+			for i := 0; i+len(keyword) <= len(text); i++ {
+				if text[i:i+len(keyword)] == keyword {
+					want = append(want, KeywordMatch{keyword, i, i + len(keyword)})
+				}
+			}
+		}
+		got := NewKeywordMatcher(keywords...).FindAll(text)
+		sortMatches(want)
+		sortMatches(got)
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("keywords %q text %q: got %v, want %v", keywords, text, got, want)
+		}
+	}
+}
+
+func sortMatches(matches []KeywordMatch) {
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Start != matches[j].Start {
+			return matches[i].Start < matches[j].Start
+		}
+		return matches[i].End < matches[j].End
+	})
+}
+
+// 匹配过程中重建关键词，每次匹配的结果来自某一组完整的关键词
+func TestKeywordMatcherConcurrentBuild(t *testing.T) {
+	sets := [][]string{{"广告", "加群"}, {"代练", "加群", "vx"}}
+	m := NewKeywordMatcher(sets[0]...)
+	text := "代练加群vx广告"
+	valid := map[string]bool{"加群,广告": true, "代练,加群,vx": true}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := 0; n < 2000; n++ {
+				var keywords []string
+				for _, match := range m.FindAll(text) {
+					keywords = append(keywords, match.Keyword)
+				}
+				if key := strings.Join(keywords, ","); !valid[key] {
+					t.Errorf("mixed result %q", key)
+					return
+				}
+			}
+		}()
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	for i := 0; ; i++ {
+		select {
+		case <-done:
+			return
+		default:
+		}
+		m.Build(sets[i%2])
+	}
+}
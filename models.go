@@ -2,31 +2,62 @@ package bililive
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net"
+	"net/http"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 )
 
 // Live 直播间
 type Live struct {
-	Debug               bool                              // 是否显示日志
-	AnalysisRoutineNum  int                               // 消息分析协程数量，默认为1，为1可以保证通知顺序与接收到消息顺序相同
-	StormFilter         bool                              // 过滤节奏风暴弹幕，默认false不过滤
-	Live                func(int)                         // 直播开始通知
-	End                 func(int)                         // 直播结束通知
-	ReceiveMsg          func(int, *MsgModel)              // 接收消息方法
-	ReceiveGift         func(int, *GiftModel)             // 接收礼物方法
-	ReceivePopularValue func(int, uint32)                 // 接收人气值方法
-	UserEnter           func(int, *UserEnterModel)        // 用户进入方法
-	GuardEnter          func(int, *GuardEnterModel)       // 舰长进入方法
-	GiftComboSend       func(int, *ComboSendModel)        // 礼物连击方法
-	GiftComboEnd        func(int, *ComboEndModel)         // 礼物连击结束方法
-	GuardBuy            func(int, *GuardBuyModel)         // 上船
-	FansUpdate          func(int, *FansUpdateModel)       // 粉丝数更新
-	RoomRank            func(int, *RankModel)             // 小时榜
-	RoomChange          func(int, *RoomChangeModel)       // 房间信息变更
-	SpecialGift         func(int, *SpecialGiftModel)      // 特殊礼物
-	SuperChatMessage    func(int, *SuperChatMessageModel) // 超级留言
-	SysMessage          func(int, *SysMsgModel)           // 系统信息
+	Debug               bool                                // 是否显示日志
+	AnalysisRoutineNum  int                                 // 消息分析协程数量，默认为1，为1可以保证通知顺序与接收到消息顺序相同
+	StormFilter         bool                                // 过滤节奏风暴弹幕，默认false不过滤
+	Filters             []Filter                            // 弹幕过滤器，按顺序执行，房间过滤器使用SetFilters设置
+	MsgFiltered         func(int, *MsgModel, *FilterResult) // 弹幕被过滤通知
+	SpamDetector        *SpamDetector                       // 刷屏检测，在节奏风暴过滤之后、Filters之前执行
+	SpamWave            func(int, *SpamWaveModel)           // 检测到刷屏通知
+	Commands            *CommandRouter                      // 弹幕命令，在过滤之后、ReceiveMsg之前处理
+	Normalizer          *Normalizer                         // 弹幕规范化，设置后在过滤前填充MsgModel.NormalizedContent
+	Live                func(int)                           // 直播开始通知，参数为加入时的房间ID，重复的开播消息只通知一次，真实房间ID等详细状态使用StatusChange或Status(roomID).Room获取
+	End                 func(int)                           // 直播结束通知，参数为加入时的房间ID，重复的下播消息只通知一次，真实房间ID使用StatusChange或Status(roomID).Room获取
+	ReceiveMsg          func(int, *MsgModel)                // 接收消息方法
+	ReceiveGift         func(int, *GiftModel)               // 接收礼物方法
+	ReceivePopularValue func(int, uint32)                   // 接收人气值方法，参数为加入时的房间ID，真实房间ID使用ResolveRoom获取
+	UserEnter           func(int, *UserEnterModel)          // 用户进入方法
+	GuardEnter          func(int, *GuardEnterModel)         // 舰长进入方法
+	GiftComboSend       func(int, *ComboSendModel)          // 礼物连击方法
+	GiftComboEnd        func(int, *ComboEndModel)           // 礼物连击结束方法
+	GuardBuy            func(int, *GuardBuyModel)           // 上船
+	FansUpdate          func(int, *FansUpdateModel)         // 粉丝数更新
+	RoomRank            func(int, *RankModel)               // 小时榜
+	RoomChange          func(int, *RoomChangeModel)         // 房间信息变更
+	SpecialGift         func(int, *SpecialGiftModel)        // 特殊礼物
+	SuperChatMessage    func(int, *SuperChatMessageModel)   // 超级留言
+	SuperChatMessageJPN func(int, *SuperChatMessageModel)   // 超级留言日文翻译（已按ID合并到原留言）
+	SuperChatDelete     func(int, *SuperChatDeleteModel)    // 超级留言删除
+	SysMessage          func(int, *SysMsgModel)             // 系统信息
+	GiftCombo           func(int, *GiftComboModel)          // 合并后的礼物连击，设置后启用礼物合并，在单独的协程中按合并结束的顺序调用
+	GiftComboWindow     time.Duration                       // 礼物合并静默时间，超过该时间没有新礼物则输出合并结果，默认3秒
+	RevenueLedger       bool                                // 统计每场直播的收益，默认false不统计
+	RevenueSummary      func(int, *RevenueSummary)          // 直播结束时的收益汇总，在End之前调用
+	Credential          *Credential                         // 登录凭据，发送弹幕等需要登录的接口使用
+	HTTPClient          *http.Client                        // HTTP客户端，默认跳过证书验证、超时10秒
+	APIBaseURL          string                              // 接口地址，默认https://api.live.bilibili.com，测试时可以指向本地服务
+	RoomInfoTTL         time.Duration                       // 房间信息缓存时间，默认1分钟
+	StatusChange        func(int, *RoomStatus)              // 直播状态变化通知，重复的状态只通知一次，在Live和End之前调用
+	StatusPollInterval  time.Duration                       // 直播状态轮询间隔，用于补充重连期间漏掉的开播、下播消息，0不轮询
+	OnSessionEnd        func(int, *Session)                 // 一场直播结束，附带场次统计，设置后启用场次记录，在End之前调用
+	WatchInterval       time.Duration                       // 关注列表查询间隔，默认1分钟
+	WatchBatchSize      int                                 // 关注列表每次批量查询的房间数量，默认50
+	WatchGrace          time.Duration                       // 关注的房间下播后多久移出，默认5分钟
+	Archiver            *DanmakuArchiver                    // 弹幕存档，每场直播保存为一个XML文件
+	Recorder            *StreamRecorder                     // 直播录制，开播时开始录制，下播时停止
 
 	wg  sync.WaitGroup
 	ctx context.Context
@@ -34,27 +65,44 @@ type Live struct {
 	chSocketMessage chan *socketMessage
 	chOperation     chan *operateInfo
 
-	storming     map[int]bool             // 是否节奏风暴
-	stormContent map[int]map[int64]string // 节奏风暴内容
+	storms  *stormTracker // 节奏风暴
+	filters *filterChain  // 弹幕过滤器链
 
-	room map[int]*liveRoom // 直播间
+	superChats *superChatCache      // 醒目留言缓存，用于按ID合并日文翻译
+	giftCombos *giftComboAggregator // 礼物连击合并
+	ledger     *revenueLedger       // 收益账本
+	lotteries  *lotteryManager      // 口令抽奖
+	polls      *pollManager         // 弹幕投票
+	queues     *queueManager        // 观众排队
+	roomInfos  roomInfoCache        // 房间信息缓存
+	statuses   *statusTracker       // 直播状态
+	sessions   *sessionTracker      // 场次记录
+	watcher    *roomWatcher         // 关注列表
+
+	room     map[int]*liveRoom // 直播间
+	roomLock sync.Mutex        // 保护room，Join、Remove可以在不同协程调用
 }
 
 type socketMessage struct {
-	roomID int // 房间ID（兼容短ID）
+	roomID int   // 房间ID（兼容短ID）
+	room   *Room // 房间ID记录
 	body   []byte
 }
 
 type liveRoom struct {
+	live               *Live
 	roomID             int // 房间ID（兼容短ID）
 	realRoomID         int
+	infoLock           sync.Mutex
+	info               *Room // 房间ID记录，获取服务器后更新，重连时在receive协程中更新，使用getInfo读取
 	cancel             context.CancelFunc
 	server             string // 地址
 	port               int    // 端口
 	hostServerList     []*hostServerList
 	currentServerIndex int
 	token              string // key
-	conn               *net.TCPConn
+	connLock           sync.Mutex
+	conn               *net.TCPConn // 连接，重连时在receive协程中替换，使用getConn读取
 }
 
 type messageHeader struct {
@@ -67,6 +115,7 @@ type messageHeader struct {
 
 type operateInfo struct {
 	RoomID    int
+	Room      *Room
 	Operation int32
 	Buffer    []byte
 }
@@ -82,22 +131,19 @@ type enterInfo struct {
 	Key       string `json:"key"`
 }
 
-// 房间信息
-type roomInfoResult struct {
-	Code int           `json:"code"`
-	Data *roomInfoData `json:"data"`
-}
-
 // 房间数据
 type roomInfoData struct {
-	RoomID int `json:"room_id"`
+	RoomID     int   `json:"room_id"`
+	ShortID    int   `json:"short_id"`
+	UID        int64 `json:"uid"`
+	LiveStatus int   `json:"live_status"`
+	LiveTime   int64 `json:"live_time"`
+	IsHidden   bool  `json:"is_hidden"`
+	IsLocked   bool  `json:"is_locked"`
+	Encrypted  bool  `json:"encrypted"`
 }
 
 // 弹幕信息
-type danmuConfigResult struct {
-	Data *danmuData `json:"data"`
-}
-
 type danmuData struct {
 	Host           string            `json:"host"`
 	Port           int               `json:"port"`
@@ -112,6 +158,13 @@ type hostServerList struct {
 	WsPort  int    `json:"ws_port"`
 }
 
+// Room 房间ID记录，回调中的房间ID为加入时使用的ID，可能是短号
+type Room struct {
+	RoomID     int `json:"room_id"`      // 加入时使用的房间ID
+	RealRoomID int `json:"real_room_id"` // 真实房间ID
+	ShortID    int `json:"short_id"`     // 短号，没有时为0
+}
+
 // 命令模型
 type cmdModel struct {
 	CMD  string                 `json:"cmd"`
@@ -124,6 +177,7 @@ type SysMsgModel struct {
 	Cmd     string `json:"cmd"`
 	Msg     string `json:"msg"`
 	MsgText string `json:"msg_text"`
+	Room    *Room  `json:"-"` // 所属房间
 }
 
 // UserEnterModel 用户进入模型
@@ -133,6 +187,7 @@ type UserEnterModel struct {
 	IsAdmin  bool   `json:"is_admin"`
 	VIP      int    `json:"vip"`
 	SVIP     int    `json:"svip"`
+	Room     *Room  `json:"-"` // 所属房间
 }
 
 // GuardEnterModel 舰长进入模型
@@ -140,20 +195,69 @@ type GuardEnterModel struct {
 	UserID     int64  `json:"uid"`
 	UserName   string `json:"username"`
 	GuardLevel int    `json:"guard_level"`
+	Room       *Room  `json:"-"` // 所属房间
 }
 
 // GiftModel 礼物模型
 type GiftModel struct {
-	GiftName  string `json:"giftName"`       // 礼物名称
-	Num       int    `json:"num"`            // 数量
-	UserName  string `json:"uname"`          // 用户名称
-	UserID    int64  `json:"uid"`            // 用户ID
-	GiftID    int    `json:"giftId"`         // 礼物ID
-	Price     int    `json:"price"`          // 价格
-	CoinType  string `json:"coin_type"`      // 硬币类型
-	FaceURL   string `json:"face"`           // 头像url
-	Combo     int    `json:"super_gift_num"` // 连击
-	Timestamp int64  `json:"timestamp"`      // 时间
+	GiftName       string     `json:"giftName"`         // 礼物名称
+	Num            int        `json:"num"`              // 数量
+	UserName       string     `json:"uname"`            // 用户名称
+	UserID         int64      `json:"uid"`              // 用户ID
+	GiftID         int        `json:"giftId"`           // 礼物ID
+	GiftType       int        `json:"giftType"`         // 礼物类型
+	Price          int        `json:"price"`            // 价格（盲盒为开出礼物的价格）
+	DiscountPrice  int        `json:"discount_price"`   // 折扣价格
+	TotalCoin      int        `json:"total_coin"`       // 总价值（瓜子）
+	CoinType       string     `json:"coin_type"`        // 硬币类型
+	Action         string     `json:"action"`           // 动作，如"投喂"
+	FaceURL        string     `json:"face"`             // 头像url
+	FaceFrame      string     `json:"face_frame"`       // 头像框
+	FaceEffectID   int        `json:"face_effect_id"`   // 头像特效ID
+	GuardLevel     int        `json:"guard_level"`      // 舰长等级
+	MedalInfo      *MedalInfo `json:"medal_info"`       // 粉丝勋章
+	BatchComboID   string     `json:"batch_combo_id"`   // 批量连击ID
+	ComboTotalCoin int        `json:"combo_total_coin"` // 连击总价值
+	BlindGift      *BlindGift `json:"blind_gift"`       // 盲盒信息，非盲盒礼物为nil
+	Combo          int        `json:"super_gift_num"`   // 连击
+	Timestamp      int64      `json:"timestamp"`        // 时间
+	Room           *Room      `json:"-"`                // 所属房间
+}
+
+// BlindGift 盲盒信息
+// 盲盒礼物推送的GiftID/GiftName/Price为开出的礼物，Original开头的字段为用户实际购买的盲盒
+type BlindGift struct {
+	ConfigID          int    `json:"blind_gift_config_id"` // 盲盒配置ID
+	From              int    `json:"from"`                 // 来源
+	GiftAction        string `json:"gift_action"`          // 动作，如"爆出"
+	GiftTipPrice      int    `json:"gift_tip_price"`       // 开出礼物价格
+	OriginalGiftID    int    `json:"original_gift_id"`     // 盲盒礼物ID
+	OriginalGiftName  string `json:"original_gift_name"`   // 盲盒礼物名称
+	OriginalGiftPrice int    `json:"original_gift_price"`  // 盲盒价格
+}
+
+// IsBlindGift 是否盲盒礼物
+func (m *GiftModel) IsBlindGift() bool {
+	return m.BlindGift != nil && m.BlindGift.OriginalGiftID > 0
+}
+
+// PaidCoin 用户实际支付的瓜子数，盲盒按盲盒价格计算
+func (m *GiftModel) PaidCoin() int {
+	if m.IsBlindGift() {
+		return m.BlindGift.OriginalGiftPrice * m.Num
+	}
+	if m.DiscountPrice > 0 {
+		return m.DiscountPrice * m.Num
+	}
+	return m.Price * m.Num
+}
+
+// RevealedCoin 礼物本身的价值，盲盒为开出礼物的价值
+func (m *GiftModel) RevealedCoin() int {
+	if m.TotalCoin > 0 && !m.IsBlindGift() {
+		return m.TotalCoin
+	}
+	return m.Price * m.Num
 }
 
 // MsgModel 消息
@@ -161,32 +265,95 @@ type MsgModel struct {
 	UserID      int64  // 用户ID
 	UserName    string // 用户昵称
 	UserLevel   int    // 用户等级
+	IsAdmin     bool   // 是否房管
+	GuardLevel  int    // 舰长等级，1总督 2提督 3舰长，0不是舰长
 	MedalName   string // 勋章名
 	MedalUpName string // 勋章主播名称
 	MedalRoomID int64  // 勋章直播间ID
 	MedalLevel  int    // 勋章等级
 	Content     string // 内容
 	Timestamp   int64  // 时间
+	Mode        int    // 弹幕模式，1滚动 4底部 5顶部
+	FontSize    int    // 字号
+	Color       int    // 颜色
+	SendTime    int64  // 发送时间（毫秒）
+	UserHash    string // 用户ID的crc32，与视频弹幕的midHash相同
+	ID          string // 弹幕ID，没有时为随机数
+
+	NormalizedContent string // 规范化后的内容，设置了Normalizer时有值
+	Room              *Room  `json:"-"` // 所属房间
+
+	normalizer *Normalizer // 生成NormalizedContent的规范化，过滤器用它规范化关键词
+}
+
+// 弹幕附加信息
+type danmakuExtra struct {
+	IDStr string `json:"id_str"`
+}
+
+// Text 过滤和匹配使用的文本，有规范化内容时返回规范化内容
+func (m *MsgModel) Text() string {
+	if m.NormalizedContent != "" {
+		return m.NormalizedContent
+	}
+	return m.Content
+}
+
+// 用生成规范化内容的规则规范化关键词，没有规范化时原样返回
+func (m *MsgModel) normalize(s string) string {
+	if m.normalizer == nil || m.NormalizedContent == "" {
+		return s
+	}
+	return m.normalizer.Normalize(s)
 }
 
 // ComboSendModel 连击模型
 type ComboSendModel struct {
-	UserName string `json:"uname"`     // 用户名称
-	GiftName string `json:"gift_name"` // 礼物名称
-	GiftID   int    `json:"gift_id"`   // 礼物ID
-	ComboNum int    `json:"combo_num"` // 连击数量
+	UserID         int64  `json:"uid"`              // 用户ID
+	UserName       string `json:"uname"`            // 用户名称
+	GiftName       string `json:"gift_name"`        // 礼物名称
+	GiftID         int    `json:"gift_id"`          // 礼物ID
+	GiftNum        int    `json:"gift_num"`         // 单次赠送数量
+	ComboNum       int    `json:"combo_num"`        // 连击数量
+	TotalNum       int    `json:"total_num"`        // 总数量
+	ComboTotalCoin int    `json:"combo_total_coin"` // 连击总价值
+	BatchComboID   string `json:"batch_combo_id"`   // 批量连击ID
+	Room           *Room  `json:"-"`                // 所属房间
 }
 
 // ComboEndModel 连击结束模型
 type ComboEndModel struct {
-	GiftName   string `json:"gift_name"`   // 礼物名称
-	ComboNum   int    `json:"combo_num"`   // 连击数量
-	UserName   string `json:"uname"`       // 用户名称
-	GiftID     int    `json:"gift_id"`     // 礼物ID
-	Price      int    `json:"price"`       // 价格
-	GuardLevel int    `json:"guard_level"` // 舰长等级
-	StartTime  int64  `json:"start_time"`  // 开始时间
-	EndTime    int64  `json:"end_time"`    // 结束时间
+	UserID         int64  `json:"uid"`              // 用户ID
+	GiftName       string `json:"gift_name"`        // 礼物名称
+	ComboNum       int    `json:"combo_num"`        // 连击数量
+	GiftNum        int    `json:"gift_num"`         // 单次赠送数量
+	ComboTotalCoin int    `json:"combo_total_coin"` // 连击总价值
+	UserName       string `json:"uname"`            // 用户名称
+	GiftID         int    `json:"gift_id"`          // 礼物ID
+	Price          int    `json:"price"`            // 价格
+	GuardLevel     int    `json:"guard_level"`      // 舰长等级
+	StartTime      int64  `json:"start_time"`       // 开始时间
+	EndTime        int64  `json:"end_time"`         // 结束时间
+	BatchComboID   string `json:"batch_combo_id"`   // 批量连击ID
+	Room           *Room  `json:"-"`                // 所属房间
+}
+
+// GiftComboModel 合并后的礼物连击模型
+type GiftComboModel struct {
+	BatchComboID string // 批量连击ID，可能为空
+	UserID       int64  // 用户ID
+	UserName     string // 用户名称
+	FaceURL      string // 头像url
+	GuardLevel   int    // 舰长等级
+	GiftID       int    // 礼物ID
+	GiftName     string // 礼物名称
+	CoinType     string // 硬币类型
+	Num          int    // 礼物总数量
+	TotalCoin    int    // 实际支付的总价值（瓜子）
+	Count        int    // 合并的礼物消息条数
+	StartTime    int64  // 第一条礼物时间
+	EndTime      int64  // 最后一条礼物时间
+	Room         *Room  // 所属房间
 }
 
 // GuardBuyModel 上船模型
@@ -198,13 +365,15 @@ type GuardBuyModel struct {
 	GiftID     int    `json:"gift_id"`     // 礼物ID
 	Price      int    `json:"price"`       // 价格
 	GuardLevel int    `json:"guard_level"` // 舰长等级
+	Room       *Room  `json:"-"`           // 所属房间
 }
 
 // FansUpdateModel 粉丝更新模型
 type FansUpdateModel struct {
-	RoomID    int `json:"roomid"`
-	Fans      int `json:"fans"`
-	RedNotice int `json:"red_notice"`
+	RoomID    int   `json:"roomid"`
+	Fans      int   `json:"fans"`
+	RedNotice int   `json:"red_notice"`
+	Room      *Room `json:"-"` // 所属房间
 }
 
 // RankModel 小时榜模型
@@ -212,6 +381,7 @@ type RankModel struct {
 	RoomID    int    `json:"roomid"`
 	RankDesc  string `json:"rank_desc"`
 	Timestamp int64  `json:"timestamp"`
+	Room      *Room  `json:"-"` // 所属房间
 }
 
 // RoomChangeModel 房间基础信息变更
@@ -221,25 +391,170 @@ type RoomChangeModel struct {
 	ParentAreaID   int    `json:"parent_area_id"`
 	AreaName       string `json:"area_name"`
 	ParentAreaName string `json:"parent_area_name"`
+	Room           *Room  `json:"-"` // 所属房间
 }
 
 // SpecialGiftModel 特殊礼物模型
 type SpecialGiftModel struct {
-	Storm struct {
-		ID      int64       `json:"-"`
-		TempID  interface{} `json:"id"` // 因为b站通知节奏风暴开始和结束id类型不同，用这个变量作为中转
-		Action  string      `json:"action"`
-		Content string      `json:"content"`
-		Num     int         `json:"num"`
-		Time    int         `json:"time"`
-	} `json:"39"`
+	Storm SpecialGiftStorm `json:"39"`
+	Room  *Room            `json:"-"` // 所属房间
+}
+
+// SpecialGiftStorm 节奏风暴
+type SpecialGiftStorm struct {
+	ID      int64  `json:"id"`      // 节奏风暴ID
+	Action  string `json:"action"`  // start开始，end结束
+	Content string `json:"content"` // 内容
+	Num     int    `json:"num"`     // 数量
+	Time    int    `json:"time"`    // 持续时间（秒）
+}
+
+// UnmarshalJSON b站通知节奏风暴开始和结束时id类型不同，这里统一转换
+func (m *SpecialGiftStorm) UnmarshalJSON(b []byte) error {
+	type alias SpecialGiftStorm
+	temp := &struct {
+		*alias
+		ID flexInt64 `json:"id"`
+	}{alias: (*alias)(m)}
+	if err := json.Unmarshal(b, temp); err != nil {
+		return err
+	}
+	m.ID = int64(temp.ID)
+	return nil
 }
 
 // SuperChatMessageModel 超级留言模型
 type SuperChatMessageModel struct {
-	Price    int    `json:"price"`
-	Message  string `json:"message"`
-	UserInfo struct {
-		UserName string `json:"uname"`
-	} `json:"user_info"`
+	ID                    int64          `json:"id"`                      // 醒目留言ID
+	UserID                int64          `json:"uid"`                     // 用户ID
+	Price                 int            `json:"price"`                   // 价格（元）
+	Rate                  int            `json:"rate"`                    // 人民币与金瓜子比率
+	Message               string         `json:"message"`                 // 留言内容
+	MessageJPN            string         `json:"message_jpn"`             // 日文翻译
+	TransMark             int            `json:"trans_mark"`              // 是否有翻译
+	Token                 string         `json:"token"`                   // 令牌
+	Time                  int            `json:"time"`                    // 持续时间（秒）
+	StartTime             int64          `json:"start_time"`              // 开始时间
+	EndTime               int64          `json:"end_time"`                // 结束时间
+	Timestamp             int64          `json:"ts"`                      // 时间
+	BackgroundColor       string         `json:"background_color"`        // 背景色
+	BackgroundBottomColor string         `json:"background_bottom_color"` // 底部背景色
+	BackgroundColorStart  string         `json:"background_color_start"`  // 渐变起始色
+	BackgroundColorEnd    string         `json:"background_color_end"`    // 渐变结束色
+	BackgroundPriceColor  string         `json:"background_price_color"`  // 价格背景色
+	BackgroundImage       string         `json:"background_image"`        // 背景图片
+	BackgroundIcon        string         `json:"background_icon"`         // 背景图标
+	MessageFontColor      string         `json:"message_font_color"`      // 留言字体颜色
+	MedalInfo             *MedalInfo     `json:"medal_info"`              // 粉丝勋章
+	UserInfo              SuperChatUser  `json:"user_info"`               // 用户信息
+	Gift                  *SuperChatGift `json:"gift"`                    // 对应礼物
+	Room                  *Room          `json:"-"`                       // 所属房间
+}
+
+// UnmarshalJSON 日文翻译消息中的ID和用户ID为字符串，这里统一转换
+func (m *SuperChatMessageModel) UnmarshalJSON(b []byte) error {
+	type alias SuperChatMessageModel
+	temp := &struct {
+		*alias
+		ID     flexInt64 `json:"id"`
+		UserID flexInt64 `json:"uid"`
+	}{alias: (*alias)(m)}
+	// 个别字段类型不符时其他字段仍然会被解析，ID不能因此丢失
+	err := json.Unmarshal(b, temp)
+	var typeErr *json.UnmarshalTypeError
+	if err != nil && !errors.As(err, &typeErr) {
+		return err
+	}
+	m.ID = int64(temp.ID)
+	m.UserID = int64(temp.UserID)
+	return err
+}
+
+// SuperChatUser 醒目留言用户信息
+type SuperChatUser struct {
+	UserName   string `json:"uname"`       // 用户名称
+	FaceURL    string `json:"face"`        // 头像url
+	FaceFrame  string `json:"face_frame"`  // 头像框
+	GuardLevel int    `json:"guard_level"` // 舰长等级
+	UserLevel  int    `json:"user_level"`  // 用户等级
+	IsVIP      int    `json:"is_vip"`      // 是否老爷
+	IsSVIP     int    `json:"is_svip"`     // 是否年费老爷
+	IsMainVIP  int    `json:"is_main_vip"` // 是否大会员
+	Manager    int    `json:"manager"`     // 是否房管
+	Title      string `json:"title"`       // 头衔
+	NameColor  string `json:"name_color"`  // 名称颜色
+	LevelColor string `json:"level_color"` // 等级颜色
+}
+
+// SuperChatGift 醒目留言对应礼物
+type SuperChatGift struct {
+	GiftID   int    `json:"gift_id"`   // 礼物ID
+	GiftName string `json:"gift_name"` // 礼物名称
+	Num      int    `json:"num"`       // 数量
+}
+
+// SuperChatDeleteModel 醒目留言删除模型
+type SuperChatDeleteModel struct {
+	IDs  []int64 `json:"ids"` // 被删除的醒目留言ID
+	Room *Room   `json:"-"`   // 所属房间
+}
+
+// MedalInfo 粉丝勋章信息
+type MedalInfo struct {
+	AnchorRoomID int    `json:"anchor_roomid"` // 勋章直播间ID
+	AnchorName   string `json:"anchor_uname"`  // 勋章主播名称
+	TargetID     int64  `json:"target_id"`     // 勋章主播ID
+	MedalName    string `json:"medal_name"`    // 勋章名
+	MedalLevel   int    `json:"medal_level"`   // 勋章等级
+	MedalColor   Color  `json:"medal_color"`   // 勋章颜色
+	GuardLevel   int    `json:"guard_level"`   // 舰长等级
+	IsLighted    int    `json:"is_lighted"`    // 是否点亮
+}
+
+// Color 颜色，b站有时为数字，有时为"#1a544b"格式的字符串
+type Color int
+
+// UnmarshalJSON 同时支持数字和十六进制字符串
+func (c *Color) UnmarshalJSON(b []byte) error {
+	s := strings.Trim(string(b), `"`)
+	if s == "" || s == "null" {
+		*c = 0
+		return nil
+	}
+	base := 10
+	if strings.HasPrefix(s, "#") {
+		s, base = s[1:], 16
+	}
+	v, err := strconv.ParseInt(s, base, 64)
+	if err != nil {
+		return err
+	}
+	*c = Color(v)
+	return nil
+}
+
+// Hex 十六进制格式，如#1a544b
+func (c Color) Hex() string {
+	return fmt.Sprintf("#%06x", int(c))
+}
+
+// flexInt64 兼容b站同一字段有时为数字有时为字符串的情况
+type flexInt64 int64
+
+func (i *flexInt64) UnmarshalJSON(b []byte) error {
+	s := strings.Trim(string(b), `"`)
+	if s == "" || s == "null" {
+		*i = 0
+		return nil
+	}
+	if v, err := strconv.ParseInt(s, 10, 64); err == nil {
+		*i = flexInt64(v)
+		return nil
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return err
+	}
+	*i = flexInt64(v)
+	return nil
 }
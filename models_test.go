@@ -0,0 +1,82 @@
+package bililive
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// 抓取的SUPER_CHAT_MESSAGE，medal_info.medal_color为字符串
+const superChatPayload = `{"cmd":"SUPER_CHAT_MESSAGE","data":{"background_bottom_color":"#2A60B2","background_color":"#EDF5FF","background_color_end":"#405D85","background_color_start":"#3171D2","background_icon":"","background_image":"https://i0.hdslb.com/bfs/live/a712efa5c6ebc67bafbe8352d3e74b820a00c13e.png","background_price_color":"#7497CD","color_point":0.7,"dmscore":112,"end_time":1650000060,"gift":{"gift_id":12000,"gift_name":"醒目留言","num":1},"id":3953745,"is_ranked":0,"is_send_audit":0,"medal_info":{"anchor_roomid":22603245,"anchor_uname":"某主播","guard_level":3,"icon_id":0,"is_lighted":1,"medal_color":"#1a544b","medal_color_border":6809855,"medal_color_end":6850801,"medal_color_start":398668,"medal_level":21,"medal_name":"某某","special":"","target_id":1437582453},"message":"晚上好","message_font_color":"#A3F6FF","message_trans":"","price":30,"rate":1000,"start_time":1650000000,"time":60,"token":"8F3B4A2C","trans_mark":0,"ts":1650000000,"uid":12345678,"user_info":{"face":"http://i0.hdslb.com/bfs/face/member/noface.jpg","face_frame":"","guard_level":3,"is_main_vip":1,"is_svip":0,"is_vip":0,"level_color":"#5896de","manager":0,"name_color":"#00D1F1","title":"0","uname":"观众","user_level":20}},"roomid":22603245}`
+
+// 抓取的SUPER_CHAT_MESSAGE_JPN，ID和用户ID为字符串
+const superChatJPNPayload = `{"cmd":"SUPER_CHAT_MESSAGE_JPN","data":{"id":"3953745","uid":"12345678","price":30,"rate":1000,"message":"晚上好","message_jpn":"こんばんは","is_ranked":0,"background_image":"https://i0.hdslb.com/bfs/live/a712efa5c6ebc67bafbe8352d3e74b820a00c13e.png","background_color":"#EDF5FF","background_icon":"","background_price_color":"#7497CD","background_bottom_color":"#2A60B2","ts":1650000000,"token":"8F3B4A2C","medal_info":{"icon_id":0,"target_id":1437582453,"special":"","anchor_uname":"某主播","anchor_roomid":22603245,"medal_level":21,"medal_name":"某某","medal_color":"#1a544b"},"user_info":{"uname":"观众","face":"http://i0.hdslb.com/bfs/face/member/noface.jpg","face_frame":"","guard_level":3,"user_level":20,"level_color":"#5896de","is_vip":0,"is_svip":0,"is_main_vip":1},"time":60,"start_time":1650000000,"end_time":1650000060,"gift":{"num":1,"gift_id":12000,"gift_name":"醒目留言"}},"roomid":"22603245"}`
+
+func decodeSuperChat(t *testing.T, payload string) *SuperChatMessageModel {
+	t.Helper()
+	result := cmdModel{}
+	if err := json.Unmarshal([]byte(payload), &result); err != nil {
+		t.Fatal(err)
+	}
+	// 与analysis相同，先解析外层再重新编码data
+	temp, err := json.Marshal(result.Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &SuperChatMessageModel{}
+	if err := json.Unmarshal(temp, m); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestSuperChatUnmarshal(t *testing.T) {
+	m := decodeSuperChat(t, superChatPayload)
+	if m.ID != 3953745 || m.UserID != 12345678 {
+		t.Fatalf("id=%d uid=%d", m.ID, m.UserID)
+	}
+	if m.Price != 30 || m.Message != "晚上好" || m.UserInfo.UserName != "观众" {
+		t.Fatalf("%+v", m)
+	}
+	if m.MedalInfo == nil || m.MedalInfo.MedalColor != 0x1a544b || m.MedalInfo.MedalColor.Hex() != "#1a544b" {
+		t.Fatalf("medal %+v", m.MedalInfo)
+	}
+
+	jpn := decodeSuperChat(t, superChatJPNPayload)
+	if jpn.ID != 3953745 || jpn.UserID != 12345678 || jpn.MessageJPN != "こんばんは" {
+		t.Fatalf("jpn %+v", jpn)
+	}
+}
+
+func TestSuperChatMergeAndDelete(t *testing.T) {
+	cache := newSuperChatCache()
+	m := decodeSuperChat(t, superChatPayload)
+	jpn := decodeSuperChat(t, superChatJPNPayload)
+	// 抓取的消息已经过期，改为还在显示
+	m.EndTime = time.Now().Add(time.Minute).Unix()
+	jpn.EndTime = m.EndTime
+
+	cache.add(1, m)
+	merged := cache.mergeJPN(1, jpn)
+	if merged.Message != "晚上好" || merged.MessageJPN != "こんばんは" || merged.UserID != 12345678 {
+		t.Fatalf("merged %+v", merged)
+	}
+	cache.remove(1, []int64{3953745})
+	if again := cache.mergeJPN(1, jpn); again.Message != jpn.Message || again.Token != jpn.Token || again == merged {
+		t.Fatal("not removed")
+	}
+}
+
+func TestColorUnmarshal(t *testing.T) {
+	var v struct {
+		A Color `json:"a"`
+		B Color `json:"b"`
+		C Color `json:"c"`
+	}
+	if err := json.Unmarshal([]byte(`{"a":6067854,"b":"#5c968e","c":""}`), &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.A != 6067854 || v.B != 0x5c968e || v.C != 0 {
+		t.Fatalf("%+v", v)
+	}
+}
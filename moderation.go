@@ -0,0 +1,191 @@
+package bililive
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	addSilentUserPath     = "/xlive/web-ucenter/v1/banned/AddSilentUser"
+	delSilentUserPath     = "/xlive/web-ucenter/v1/banned/DelSilentUser"
+	getSilentUserListPath = "/xlive/web-ucenter/v1/banned/GetSilentUserList"
+	addShieldKeywordPath  = "/xlive/web-ucenter/v1/banned/AddShieldKeyword"
+	delShieldKeywordPath  = "/xlive/web-ucenter/v1/banned/DelShieldKeyword"
+	getShieldKeywordPath  = "/xlive/web-ucenter/v1/banned/GetShieldKeywordList"
+	roomSilentPath        = "/xlive/web-ucenter/v1/banned/RoomSilent"
+	appointAdminPath      = "/xlive/app-ucenter/v1/roomAdmin/appoint"
+	dismissAdminPath      = "/xlive/app-ucenter/v1/roomAdmin/dismiss"
+)
+
+// 禁言时长
+const (
+	MuteThisLive time.Duration = 0  // 本场直播
+	MuteForever  time.Duration = -1 // 永久
+)
+
+// 全员禁言类型
+const (
+	RoomSilentLevel  = "level"  // 用户等级低于Level的禁言
+	RoomSilentMedal  = "medal"  // 粉丝勋章等级低于Level的禁言
+	RoomSilentMember = "member" // 全员禁言
+	RoomSilentOff    = "off"    // 取消全员禁言
+)
+
+// ErrPermissionDenied 没有房管权限
+var ErrPermissionDenied = errors.New("没有操作权限")
+
+// MutedUser 被禁言用户
+type MutedUser struct {
+	ID           int64  `json:"id"`             // 禁言记录ID
+	UserID       int64  `json:"tuid"`           // 被禁言用户ID
+	UserName     string `json:"tname"`          // 被禁言用户名
+	OperatorID   int64  `json:"uid"`            // 操作人ID
+	OperatorName string `json:"name"`           // 操作人名称
+	CreateTime   string `json:"ctime"`          // 禁言时间
+	EndTime      string `json:"block_end_time"` // 解除时间
+}
+
+// 禁言列表
+type mutedUserPage struct {
+	Data      []*MutedUser `json:"data"`
+	Total     int          `json:"total"`
+	TotalPage int          `json:"total_page"`
+}
+
+// ShieldKeyword 房间屏蔽词
+type ShieldKeyword struct {
+	Keyword string `json:"keyword"` // 屏蔽词
+	Name    string `json:"name"`    // 添加人
+	UserID  int64  `json:"uid"`     // 添加人ID
+}
+
+// 屏蔽词列表
+type shieldKeywordList struct {
+	KeywordList []*ShieldKeyword `json:"keyword_list"`
+}
+
+// RoomSilentOptions 全员禁言选项
+type RoomSilentOptions struct {
+	Type     string        // 禁言类型，RoomSilentLevel、RoomSilentMedal或RoomSilentMember
+	Level    int           // 等级，Type为level或medal时有效
+	Duration time.Duration // 时长，按分钟取整，0为本场直播
+}
+
+// MuteUser 禁言用户，duration按小时向上取整，MuteThisLive为本场直播，MuteForever为永久
+func (live *Live) MuteUser(ctx context.Context, roomID int, userID int64, duration time.Duration) error {
+	hour := -1
+	if duration >= 0 {
+		hour = int((duration + time.Hour - 1) / time.Hour)
+	}
+	return live.moderate(ctx, roomID, addSilentUserPath, url.Values{
+		"tuid":       {strconv.FormatInt(userID, 10)},
+		"hour":       {strconv.Itoa(hour)},
+		"mobile_app": {"web"},
+	})
+}
+
+// UnmuteUser 解除禁言
+func (live *Live) UnmuteUser(ctx context.Context, roomID int, userID int64) error {
+	return live.moderate(ctx, roomID, delSilentUserPath, url.Values{
+		"tuid": {strconv.FormatInt(userID, 10)},
+	})
+}
+
+// MutedUsers 房间当前被禁言的用户
+func (live *Live) MutedUsers(ctx context.Context, roomID int) ([]*MutedUser, error) {
+	realRoomID, err := live.realRoomID(ctx, roomID)
+	if err != nil {
+		return nil, err
+	}
+	var result []*MutedUser
+	for page := 1; ; page++ {
+		data := &mutedUserPage{}
+		_, err := live.apiPost(ctx, getSilentUserListPath, url.Values{
+			"room_id": {strconv.Itoa(realRoomID)},
+			"ps":      {strconv.Itoa(page)},
+		}, data)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, data.Data...)
+		if page >= data.TotalPage || len(data.Data) == 0 {
+			return result, nil
+		}
+	}
+}
+
+// AddShieldKeyword 添加房间屏蔽词
+func (live *Live) AddShieldKeyword(ctx context.Context, roomID int, keyword string) error {
+	return live.moderate(ctx, roomID, addShieldKeywordPath, url.Values{"keyword": {keyword}})
+}
+
+// RemoveShieldKeyword 删除房间屏蔽词
+func (live *Live) RemoveShieldKeyword(ctx context.Context, roomID int, keyword string) error {
+	return live.moderate(ctx, roomID, delShieldKeywordPath, url.Values{"keyword": {keyword}})
+}
+
+// ShieldKeywords 房间屏蔽词列表
+func (live *Live) ShieldKeywords(ctx context.Context, roomID int) ([]*ShieldKeyword, error) {
+	if live.Credential == nil {
+		return nil, ErrNoCredential
+	}
+	realRoomID, err := live.realRoomID(ctx, roomID)
+	if err != nil {
+		return nil, err
+	}
+	data := &shieldKeywordList{}
+	if _, err := live.apiGet(ctx, getShieldKeywordPath, url.Values{"room_id": {strconv.Itoa(realRoomID)}}, data); err != nil {
+		return nil, err
+	}
+	return data.KeywordList, nil
+}
+
+// SetRoomSilent 开启全员禁言
+func (live *Live) SetRoomSilent(ctx context.Context, roomID int, opts RoomSilentOptions) error {
+	if opts.Type == "" || opts.Type == RoomSilentOff {
+		return errors.New("禁言类型不正确")
+	}
+	return live.moderate(ctx, roomID, roomSilentPath, url.Values{
+		"type":   {opts.Type},
+		"level":  {strconv.Itoa(opts.Level)},
+		"minute": {strconv.Itoa(int(opts.Duration / time.Minute))},
+	})
+}
+
+// CancelRoomSilent 取消全员禁言
+func (live *Live) CancelRoomSilent(ctx context.Context, roomID int) error {
+	return live.moderate(ctx, roomID, roomSilentPath, url.Values{
+		"type":   {RoomSilentOff},
+		"level":  {"0"},
+		"minute": {"0"},
+	})
+}
+
+// AppointAdmin 任命房管，只能由主播操作自己的房间
+func (live *Live) AppointAdmin(ctx context.Context, userID int64) error {
+	_, err := live.apiPost(ctx, appointAdminPath, url.Values{"admin": {strconv.FormatInt(userID, 10)}}, nil)
+	return err
+}
+
+// DismissAdmin 撤销房管，只能由主播操作自己的房间
+func (live *Live) DismissAdmin(ctx context.Context, userID int64) error {
+	_, err := live.apiPost(ctx, dismissAdminPath, url.Values{"uid": {strconv.FormatInt(userID, 10)}}, nil)
+	return err
+}
+
+// 房管操作，填充真实房间ID
+func (live *Live) moderate(ctx context.Context, roomID int, path string, form url.Values) error {
+	if live.Credential == nil {
+		return ErrNoCredential
+	}
+	realRoomID, err := live.realRoomID(ctx, roomID)
+	if err != nil {
+		return err
+	}
+	form.Set("room_id", strconv.Itoa(realRoomID))
+	_, err = live.apiPost(ctx, path, form, nil)
+	return err
+}
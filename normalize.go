@@ -0,0 +1,146 @@
+package bililive
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Normalizer 弹幕文本规范化，用于过滤和命令匹配前统一文本
+// 用户常用全角字符、繁体字、插入空格或零宽字符、形近字母绕过屏蔽词，开始使用后不要修改规则
+type Normalizer struct {
+	Width       bool // NFKC规范化，全角、半角片假名、带圈、数学字母等兼容字符转为普通字符
+	Traditional bool // 繁体转简体
+	Invisible   bool // 去除零宽等不可见字符
+	Space       bool // 去除空白字符
+	Homoglyph   bool // 形近的希腊、西里尔字母转为拉丁字母
+	Lower       bool // 字母转小写
+}
+
+// DefaultNormalizer 开启全部规则的规范化
+var DefaultNormalizer = &Normalizer{
+	Width:       true,
+	Traditional: true,
+	Invisible:   true,
+	Space:       true,
+	Homoglyph:   true,
+	Lower:       true,
+}
+
+// Normalize 使用DefaultNormalizer规范化文本
+func Normalize(s string) string {
+	return DefaultNormalizer.Normalize(s)
+}
+
+// Normalize 规范化文本
+func (n *Normalizer) Normalize(s string) string {
+	if n.Width {
+		s = norm.NFKC.String(s)
+	}
+	var b strings.Builder
+	b.Grow(len(s))
+	for _, r := range s {
+		if n.Invisible && isInvisible(r) {
+			continue
+		}
+		if n.Space && unicode.IsSpace(r) {
+			continue
+		}
+		if n.Homoglyph {
+			if v, ok := homoglyphs[r]; ok {
+				r = v
+			}
+		}
+		if n.Traditional {
+			if v, ok := traditionalToSimplified[r]; ok {
+				r = v
+			}
+		}
+		if n.Lower {
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// Wrap 包装过滤器，过滤器看到的是规范化后的弹幕，内置过滤器的关键词也会用同样的规则规范化
+func (n *Normalizer) Wrap(f Filter) Filter {
+	return FilterFunc(func(roomID int, m *MsgModel) (string, bool) {
+		normalized := *m
+		n.apply(&normalized)
+		return f.Filter(roomID, &normalized)
+	})
+}
+
+// 填充弹幕的规范化内容
+func (n *Normalizer) apply(m *MsgModel) {
+	m.NormalizedContent = n.Normalize(m.Content)
+	m.normalizer = n
+}
+
+// 是否为不可见字符
+func isInvisible(r rune) bool {
+	switch {
+	case r == 0x00AD, r == 0x034F, r == 0x061C, r == 0x115F, r == 0x1160, r == 0x180E, r == 0x3164, r == 0xFEFF, r == 0xFFA0:
+		return true
+	case r >= 0x200B && r <= 0x200F:
+		return true
+	case r >= 0x202A && r <= 0x202E:
+		return true
+	case r >= 0x2060 && r <= 0x206F:
+		return true
+	case r >= 0xFE00 && r <= 0xFE0F:
+		return true
+	case r >= 0xE0000 && r <= 0xE007F:
+		return true
+	}
+	return false
+}
+
+// 形近字母
+var homoglyphs = map[rune]rune{
+	'а': 'a', 'в': 'b', 'е': 'e', 'к': 'k', 'м': 'm', 'н': 'h', 'о': 'o', 'р': 'p', 'с': 'c', 'т': 't', 'у': 'y', 'х': 'x', 'ѕ': 's', 'і': 'i', 'ј': 'j', 'ԁ': 'd', 'ɡ': 'g', 'ո': 'n', 'ս': 'u',
+	'А': 'A', 'В': 'B', 'Е': 'E', 'К': 'K', 'М': 'M', 'Н': 'H', 'О': 'O', 'Р': 'P', 'С': 'C', 'Т': 'T', 'У': 'Y', 'Х': 'X', 'Ѕ': 'S', 'І': 'I', 'Ј': 'J',
+	'α': 'a', 'ο': 'o', 'ν': 'v', 'ρ': 'p', 'τ': 't', 'υ': 'u', 'κ': 'k', 'ι': 'i',
+	'Α': 'A', 'Β': 'B', 'Ε': 'E', 'Ζ': 'Z', 'Η': 'H', 'Ι': 'I', 'Κ': 'K', 'Μ': 'M', 'Ν': 'N', 'Ο': 'O', 'Ρ': 'P', 'Τ': 'T', 'Υ': 'Y', 'Χ': 'X',
+}
+
+// 常用繁体字与简体字对照，每两个字为一组
+const traditionalSimplifiedPairs = "" +
+	"萬万與与專专業业東东絲丝兩两嚴严喪丧個个豐丰臨临為为麗丽舉举義义烏乌樂乐喬乔習习鄉乡書书買买亂乱爭争於于虧亏雲云亞亚產产" +
+	"畝亩親亲億亿僅仅從从侖仑倉仓儀仪們们價价眾众優优會会傘伞偉伟傳传傷伤倫伦偽伪體体餘余傭佣俠侠侶侣偵侦側侧僑侨倆俩儉俭債债" +
+	"傾倾償偿儲储兒儿兌兑黨党蘭兰關关興兴養养獸兽內内岡冈冊册寫写軍军農农馮冯衝冲決决況况凍冻淨净涼凉減减湊凑幾几鳳凤憑凭凱凯" +
+	"擊击劃划劉刘則则剛刚創创刪删別别劑剂劍剑剝剥劇剧勸劝辦办務务動动勵励勁劲勞劳勢势勳勋區区醫医華华協协單单賣卖盧卢衛卫卻却" +
+	"廠厂廳厅曆历厲厉壓压厭厌廈厦廚厨縣县參参雙双發发變变敘叙疊叠葉叶號号嘆叹嚇吓呂吕嗎吗啟启吳吴員员聽听嗚呜響响啞哑嘩哗喚唤" +
+	"問问團团園园圍围圖图圓圆國国聖圣場场壞坏塊块堅坚壇坛墳坟墜坠壘垒墾垦牆墙壯壮聲声殼壳壺壶處处備备復复夠够頭头誇夸夾夹奪夺" +
+	"奮奋獎奖奧奥婦妇媽妈嬌娇孫孙學学寧宁寶宝實实寵宠審审憲宪宮宫對对尋寻導导將将爾尔塵尘嘗尝屍尸盡尽層层屬属歲岁豈岂島岛嶺岭" +
+	"崗岗峽峡幣币帥帅師师帳帐帶带幫帮幹干並并廣广慶庆廬庐庫库應应廟庙開开異异棄弃張张彌弥彎弯彈弹強强歸归當当錄录彙汇徹彻徵征" +
+	"徑径後后憶忆懷怀態态總总戀恋惡恶悶闷驚惊懲惩憂忧慘惨懇恳憐怜戲戏戰战戶户撲扑執执擴扩掃扫揚扬擾扰撫抚拋抛搶抢護护報报擔担" +
+	"擬拟攏拢擁拥攔拦撥拨擇择掛挂撈捞損损換换據据擠挤擲掷攜携搖摇攝摄擺摆斃毙斷断無无舊旧時时曠旷晝昼顯显曬晒暈晕暫暂術术機机" +
+	"殺杀雜杂權权條条來来楊杨極极構构槍枪楓枫標标欄栏樹树樣样檔档橋桥夢梦檢检樓楼歐欧歡欢歷历殘残殲歼氣气漢汉湯汤溝沟沒没滬沪" +
+	"淚泪潑泼澤泽潔洁灑洒濁浊測测濟济瀏浏渾浑濃浓濤涛漲涨漁渔淵渊漸渐溫温灣湾濕湿滿满灘滩漿浆潛潜瀾澜滅灭燈灯靈灵災灾爐炉點点" +
+	"煉炼爛烂熱热煙烟燒烧營营愛爱犧牺狀状猶犹獨独獄狱貓猫獵猎獻献環环現现瑪玛璽玺電电畫画暢畅療疗瘋疯癢痒盤盘監监蓋盖盜盗睜睁" +
+	"礦矿碼码磚砖確确禮礼禍祸禪禅離离種种稱称積积穩稳窮穷竊窃競竞筆笔節节範范築筑簡简籃篮類类糧粮緊紧紅红紀纪約约級级紙纸紋纹" +
+	"純纯納纳紐纽線线練练組组細细終终經经結结給给絕绝統统絡络維维綜综綠绿緒绪網网緣缘編编緩缓縮缩績绩織织繞绕繩绳繼继續续纏缠" +
+	"羅罗罰罚罷罢聯联聰聪職职聞闻肅肃腸肠膚肤腦脑腳脚臉脸膽胆艦舰艱艰藝艺蘇苏蘋苹莊庄藥药薦荐蓮莲獲获薩萨蟲虫雖虽蝦虾螞蚂蠶蚕" +
+	"補补襯衬裝装製制褲裤覺觉見见規规視视覽览觀观計计訂订認认討讨讓让訓训議议記记講讲許许論论設设訪访證证評评識识詞词試试詩诗" +
+	"誠诚話话該该詳详語语誤误說说請请諸诸讀读課课誰谁調调談谈謝谢謎谜謠谣謹谨譯译譽誉讚赞貝贝負负財财貢贡貧贫貨货販贩貪贪責责" +
+	"貴贵費费貿贸資资賊贼賓宾賞赏賠赔賴赖購购賽赛贈赠贊赞贏赢趕赶趙赵趨趋躍跃蹤踪車车軌轨軟软轉转輪轮輕轻載载較较輔辅輛辆輸输" +
+	"辯辩邊边達达遷迁過过運运還还這这進进遠远連连遲迟適适選选遺遗遼辽鄧邓鄰邻醜丑釋释裡里鑒鉴針针釣钓鈔钞鈴铃鋼钢錢钱錯错鍋锅" +
+	"鍵键鎖锁鏡镜鐘钟鐵铁鑰钥錶表長长門门閃闪閉闭閑闲間间閱阅闊阔隊队陽阳陰阴陣阵階阶際际陸陆陳陈險险隨随隱隐難难雞鸡霧雾靜静" +
+	"韋韦韓韩頁页頂顶項项順顺須须預预領领頻频題题顏颜願愿風风颱台臺台飛飞飯饭飲饮館馆餓饿饑饥馬马駕驾驗验騎骑騙骗驅驱髮发鬥斗" +
+	"鬧闹魚鱼鮮鲜鳥鸟鳴鸣鴨鸭鵝鹅麥麦黃黄齊齐齒齿龍龙龜龟蒼苍麼么隻只鹹咸觸触僕仆嚮向"
+
+// 繁体转简体对照表
+var traditionalToSimplified = func() map[rune]rune {
+	runes := []rune(traditionalSimplifiedPairs)
+	m := make(map[rune]rune, len(runes)/2)
+	for i := 0; i+1 < len(runes); i += 2 {
+		if runes[i] != runes[i+1] {
+			m[runes[i]] = runes[i+1]
+		}
+	}
+	return m
+}()
@@ -0,0 +1,83 @@
+package bililive
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	cases := map[string]string{
+		"ＡＢＣ１２３": "abc123",
+		"①②③":    "123",
+		"㈠":      "(一)",
+		"℡":      "tel",
+		"ｶﾞ":     "ガ",
+		"𝐇𝐞𝐥𝐥𝐨":  "hello",
+		"二〇二四":   "二〇二四",
+		"點 歌​":   "点歌",
+		"Неllо":  "hello",
+	}
+	for in, want := range cases {
+		if got := Normalize(in); got != want {
+			t.Errorf("Normalize(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestNormalizedKeywords(t *testing.T) {
+	keywords := []string{"VPN", "加 群", "賣號"}
+	regexpFilter, err := NewRegexpFilter("VPN", "(?i)vpn代理")
+	if err != nil {
+		t.Fatal(err)
+	}
+	filters := map[string]Filter{
+		"keyword": &KeywordFilter{Keywords: keywords},
+		"matcher": NewKeywordMatcher(keywords...),
+		"regexp":  regexpFilter,
+	}
+	cases := []struct {
+		content string
+		drop    bool
+	}{
+		{"买VPN加 群", true},
+		{"买ＶＰＮ", true},
+		{"v p n", true},
+		{"加\u200b群", true},
+		{"卖号", true},
+		{"正常弹幕", false},
+	}
+	for name, f := range filters {
+		for _, c := range cases {
+			if name == "regexp" && c.content != "买VPN加 群" && c.content != "正常弹幕" {
+				continue
+			}
+			// 设置Live.Normalizer时在过滤前填充规范化内容
+			m := &MsgModel{Content: c.content}
+			DefaultNormalizer.apply(m)
+			if _, drop := f.Filter(1, m); drop != c.drop {
+				t.Errorf("%s: %q drop = %v", name, c.content, drop)
+			}
+			// 只对某个过滤器生效
+			if _, drop := DefaultNormalizer.Wrap(f).Filter(1, &MsgModel{Content: c.content}); drop != c.drop {
+				t.Errorf("%s wrapped: %q drop = %v", name, c.content, drop)
+			}
+		}
+	}
+
+	// 命中时返回原来的关键词
+	m := &MsgModel{Content: "ＶＰＮ加群"}
+	DefaultNormalizer.apply(m)
+	if reason, _ := filters["matcher"].Filter(1, m); reason != "包含屏蔽词：VPN,加 群" {
+		t.Errorf("reason %q", reason)
+	}
+	if reason, _ := filters["keyword"].Filter(1, m); reason != "包含屏蔽词：VPN" {
+		t.Errorf("reason %q", reason)
+	}
+	// 规范化后的正则
+	m = &MsgModel{Content: "ＶＰＮ代理"}
+	DefaultNormalizer.apply(m)
+	if _, drop := regexpFilter.Filter(1, m); !drop {
+		t.Error("normalized content not matched")
+	}
+	// 没有规范化时按原文匹配
+	if _, drop := filters["matcher"].Filter(1, &MsgModel{Content: "买vpn"}); drop {
+		t.Error("matched without normalizer")
+	}
+}
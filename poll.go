@@ -0,0 +1,299 @@
+package bililive
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PollOption 投票选项
+type PollOption struct {
+	Key    string // 投票口令，如"1"、"A"，不区分大小写
+	Name   string // 选项名称
+	GiftID int    // 赠送该礼物也算为该选项投票，每个礼物计GiftWeight票，0不启用
+}
+
+// PollConfig 投票配置
+type PollConfig struct {
+	Title        string                // 标题
+	Options      []PollOption          // 选项
+	Duration     time.Duration         // 投票时长，0不限制，需要手动Stop
+	LastVoteWins bool                  // 同一用户多次投票时以最后一次为准，默认以第一次为准
+	Weight       func(*MsgModel) int   // 弹幕投票权重，默认1，可以使用GuardWeight
+	GiftWeight   int                   // 每个礼物的票数，默认1
+	OnTally      func(int, *PollTally) // 票数变化通知
+	OnResult     func(int, *PollTally) // 投票结束通知
+}
+
+// GuardWeight 按舰长等级设置权重，weights的下标为舰长等级，0为普通用户
+func GuardWeight(weights ...int) func(*MsgModel) int {
+	return func(m *MsgModel) int {
+		if m.GuardLevel >= 0 && m.GuardLevel < len(weights) {
+			return weights[m.GuardLevel]
+		}
+		return 1
+	}
+}
+
+// PollTally 投票统计
+type PollTally struct {
+	Title       string             // 标题
+	Options     []*PollOptionTally // 选项统计，与配置顺序相同
+	TotalVotes  int                // 总票数
+	TotalVoters int                // 投票人数
+	StartTime   time.Time          // 开始时间
+	EndTime     time.Time          // 结束时间，未结束时为零值
+	Finished    bool               // 是否已结束
+}
+
+// PollOptionTally 选项统计
+type PollOptionTally struct {
+	Key    string // 投票口令
+	Name   string // 选项名称
+	Votes  int    // 票数（含权重）
+	Voters int    // 投票人数
+}
+
+// Winners 票数最多的选项，平票时返回多个
+func (t *PollTally) Winners() []*PollOptionTally {
+	var result []*PollOptionTally
+	for _, option := range t.Options {
+		switch {
+		case len(result) == 0 || option.Votes > result[0].Votes:
+			result = []*PollOptionTally{option}
+		case option.Votes == result[0].Votes:
+			result = append(result, option)
+		}
+	}
+	return result
+}
+
+// Poll 弹幕投票
+type Poll struct {
+	RoomID int        // 房间ID
+	Config PollConfig // 配置
+
+	sync.Mutex
+	startTime  time.Time
+	endTime    time.Time
+	finished   bool
+	timer      *time.Timer
+	keys       map[string]int // 投票口令 -> 选项下标
+	gifts      map[int]int    // 礼物ID -> 选项下标
+	votes      map[int64]pollVote
+	counts     []pollCount // 各选项的统计，投票时更新
+	totalVotes int
+}
+
+type pollVote struct {
+	option int
+	weight int
+}
+
+type pollCount struct {
+	votes  int
+	voters int
+}
+
+func newPoll(roomID int, config PollConfig) (*Poll, error) {
+	if len(config.Options) < 2 {
+		return nil, errors.New("投票至少需要两个选项")
+	}
+	p := &Poll{
+		RoomID:    roomID,
+		Config:    config,
+		startTime: time.Now(),
+		keys:      make(map[string]int),
+		gifts:     make(map[int]int),
+		votes:     make(map[int64]pollVote),
+		counts:    make([]pollCount, len(config.Options)),
+	}
+	for i, option := range config.Options {
+		key := strings.ToUpper(strings.TrimSpace(option.Key))
+		if key == "" {
+			return nil, errors.New("投票口令不能为空")
+		}
+		if _, exist := p.keys[key]; exist {
+			return nil, fmt.Errorf("投票口令 %s 重复", option.Key)
+		}
+		p.keys[key] = i
+		if option.GiftID > 0 {
+			p.gifts[option.GiftID] = i
+		}
+	}
+	if config.Duration > 0 {
+		p.timer = time.AfterFunc(config.Duration, p.Stop)
+	}
+	return p, nil
+}
+
+// Finished 是否已结束
+func (p *Poll) Finished() bool {
+	p.Lock()
+	defer p.Unlock()
+	return p.finished
+}
+
+// Tally 当前统计
+func (p *Poll) Tally() *PollTally {
+	p.Lock()
+	defer p.Unlock()
+	return p.tally()
+}
+
+// Stop 结束投票并通知结果，重复调用无效
+func (p *Poll) Stop() {
+	p.Lock()
+	if p.finished {
+		p.Unlock()
+		return
+	}
+	p.finished = true
+	p.endTime = time.Now()
+	if p.timer != nil {
+		p.timer.Stop()
+	}
+	tally := p.tally()
+	p.Unlock()
+	if p.Config.OnResult != nil {
+		p.Config.OnResult(p.RoomID, tally)
+	}
+}
+
+// 收到弹幕
+func (p *Poll) onMsg(m *MsgModel) {
+	option, ok := p.keys[strings.ToUpper(strings.TrimSpace(m.Content))]
+	if !ok {
+		return
+	}
+	weight := 1
+	if p.Config.Weight != nil {
+		weight = p.Config.Weight(m)
+	}
+	if weight <= 0 {
+		return
+	}
+	p.Lock()
+	if p.finished {
+		p.Unlock()
+		return
+	}
+	previous, voted := p.votes[m.UserID]
+	if voted {
+		if !p.Config.LastVoteWins {
+			p.Unlock()
+			return
+		}
+		// 改投时从之前的选项移出
+		p.counts[previous.option].votes -= previous.weight
+		p.counts[previous.option].voters--
+		p.totalVotes -= previous.weight
+	}
+	p.votes[m.UserID] = pollVote{option: option, weight: weight}
+	p.counts[option].votes += weight
+	p.counts[option].voters++
+	p.totalVotes += weight
+	tally := p.tally()
+	p.Unlock()
+	p.notify(tally)
+}
+
+// 收到礼物
+func (p *Poll) onGift(m *GiftModel) {
+	option, ok := p.gifts[m.GiftID]
+	if !ok {
+		return
+	}
+	weight := p.Config.GiftWeight
+	if weight <= 0 {
+		weight = 1
+	}
+	p.Lock()
+	if p.finished {
+		p.Unlock()
+		return
+	}
+	p.counts[option].votes += m.Num * weight
+	p.totalVotes += m.Num * weight
+	tally := p.tally()
+	p.Unlock()
+	p.notify(tally)
+}
+
+func (p *Poll) notify(tally *PollTally) {
+	if p.Config.OnTally != nil {
+		p.Config.OnTally(p.RoomID, tally)
+	}
+}
+
+// 统计结果，调用时需要加锁
+func (p *Poll) tally() *PollTally {
+	t := &PollTally{
+		Title:       p.Config.Title,
+		Options:     make([]*PollOptionTally, len(p.Config.Options)),
+		TotalVotes:  p.totalVotes,
+		TotalVoters: len(p.votes),
+		StartTime:   p.startTime,
+		EndTime:     p.endTime,
+		Finished:    p.finished,
+	}
+	for i, option := range p.Config.Options {
+		t.Options[i] = &PollOptionTally{
+			Key:    option.Key,
+			Name:   option.Name,
+			Votes:  p.counts[i].votes,
+			Voters: p.counts[i].voters,
+		}
+	}
+	return t
+}
+
+// 投票管理
+type pollManager struct {
+	sync.Mutex
+	rooms map[int]*Poll
+}
+
+func newPollManager() *pollManager {
+	return &pollManager{
+		rooms: make(map[int]*Poll),
+	}
+}
+
+func (pm *pollManager) get(roomID int) *Poll {
+	pm.Lock()
+	defer pm.Unlock()
+	return pm.rooms[roomID]
+}
+
+func (pm *pollManager) clear(roomID int) {
+	pm.Lock()
+	p, ok := pm.rooms[roomID]
+	delete(pm.rooms, roomID)
+	pm.Unlock()
+	if ok {
+		p.Stop()
+	}
+}
+
+// StartPoll 在房间开始投票，每个房间同时只能有一个进行中的投票
+func (live *Live) StartPoll(roomID int, config PollConfig) (*Poll, error) {
+	live.polls.Lock()
+	defer live.polls.Unlock()
+	if p, ok := live.polls.rooms[roomID]; ok && !p.Finished() {
+		return nil, fmt.Errorf("房间 %d 已有进行中的投票", roomID)
+	}
+	p, err := newPoll(roomID, config)
+	if err != nil {
+		return nil, err
+	}
+	live.polls.rooms[roomID] = p
+	return p, nil
+}
+
+// Poll 获取房间最近一次投票
+func (live *Live) Poll(roomID int) *Poll {
+	return live.polls.get(roomID)
+}
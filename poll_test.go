@@ -0,0 +1,90 @@
+package bililive
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func pollVotes(t *PollTally) []int {
+	result := make([]int, 0, 2*len(t.Options))
+	for _, option := range t.Options {
+		result = append(result, option.Votes, option.Voters)
+	}
+	return result
+}
+
+func TestPollTally(t *testing.T) {
+	options := []PollOption{{Key: "1", Name: "甲", GiftID: 100}, {Key: "b", Name: "乙"}}
+	p, err := newPoll(1, PollConfig{Options: options, Weight: GuardWeight(1, 5, 3, 2), GiftWeight: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.onMsg(&MsgModel{UserID: 1, Content: "1"})
+	p.onMsg(&MsgModel{UserID: 2, Content: " B ", GuardLevel: 3})
+	// 默认以第一次为准
+	p.onMsg(&MsgModel{UserID: 1, Content: "b"})
+	p.onMsg(&MsgModel{UserID: 3, Content: "其他"})
+	p.onGift(&GiftModel{GiftID: 100, Num: 2})
+	tally := p.Tally()
+	if got, want := pollVotes(tally), []int{21, 1, 2, 1}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("votes %v, want %v", got, want)
+	}
+	if tally.TotalVotes != 23 || tally.TotalVoters != 2 {
+		t.Fatalf("total %d %d", tally.TotalVotes, tally.TotalVoters)
+	}
+
+	// 以最后一次为准时改投会从之前的选项移出
+	var notified *PollTally
+	p, _ = newPoll(1, PollConfig{Options: options, LastVoteWins: true, OnTally: func(_ int, t *PollTally) { notified = t }})
+	p.onMsg(&MsgModel{UserID: 1, Content: "1"})
+	p.onMsg(&MsgModel{UserID: 2, Content: "1"})
+	p.onMsg(&MsgModel{UserID: 1, Content: "b"})
+	p.onMsg(&MsgModel{UserID: 1, Content: "b"})
+	if got, want := pollVotes(notified), []int{1, 1, 1, 1}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("votes %v, want %v", got, want)
+	}
+	if notified.TotalVotes != 2 || notified.TotalVoters != 2 {
+		t.Fatalf("total %d %d", notified.TotalVotes, notified.TotalVoters)
+	}
+	p.Stop()
+	p.onMsg(&MsgModel{UserID: 3, Content: "1"})
+	if tally := p.Tally(); !tally.Finished || tally.TotalVotes != 2 {
+		t.Fatalf("%+v", tally)
+	}
+}
+
+// 与按全部投票重新统计的结果比较
+func TestPollTallyIncremental(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	options := []PollOption{{Key: "1"}, {Key: "2"}, {Key: "3", GiftID: 7}}
+	p, _ := newPoll(1, PollConfig{Options: options, LastVoteWins: true, Weight: GuardWeight(1, 4, 3, 2)})
+	votes := make(map[int64]pollVote)
+	gifts := 0
+	for i := 0; i < 2000; i++ {
+		if r.Intn(10) == 0 {
+			num := 1 + r.Intn(3)
+			p.onGift(&GiftModel{GiftID: 7, Num: num})
+			gifts += num
+			continue
+		}
+		m := &MsgModel{UserID: int64(r.Intn(50)), GuardLevel: r.Intn(4), Content: options[r.Intn(3)].Key}
+		p.onMsg(m)
+		votes[m.UserID] = pollVote{option: int(m.Content[0] - '1'), weight: GuardWeight(1, 4, 3, 2)(m)}
+	}
+	want := make([]int, 6)
+	want[4] = gifts
+	total := gifts
+	for _, vote := range votes {
+		want[2*vote.option] += vote.weight
+		want[2*vote.option+1]++
+		total += vote.weight
+	}
+	tally := p.Tally()
+	if got := pollVotes(tally); !reflect.DeepEqual(got, want) {
+		t.Fatalf("votes %v, want %v", got, want)
+	}
+	if tally.TotalVotes != total || tally.TotalVoters != len(votes) {
+		t.Fatalf("total %d %d, want %d %d", tally.TotalVotes, tally.TotalVoters, total, len(votes))
+	}
+}
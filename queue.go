@@ -0,0 +1,416 @@
+package bililive
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 排队优先级，数字越大越靠前
+const (
+	QueuePriorityNormal    = 0 // 普通用户
+	QueuePriorityGuard     = 1 // 舰长
+	QueuePrioritySuperChat = 2 // 醒目留言
+)
+
+// 排队事件类型
+const (
+	QueueEventJoin     = "join"     // 加入
+	QueueEventLeave    = "leave"    // 用户取消
+	QueueEventNext     = "next"     // 轮到
+	QueueEventSkip     = "skip"     // 跳过
+	QueueEventRemove   = "remove"   // 主播移除
+	QueueEventPriority = "priority" // 优先级提升
+	QueueEventClear    = "clear"    // 清空
+)
+
+// 排队错误
+var (
+	ErrQueueFull      = errors.New("队伍已满")
+	ErrQueueUserLimit = errors.New("超过每人排队数量限制")
+	ErrQueueClosed    = errors.New("排队已关闭")
+)
+
+// QueueConfig 排队配置
+type QueueConfig struct {
+	JoinKeyword     string                      // 加入口令，默认"排队"，口令后面的内容记录到QueueEntry.Content
+	LeaveKeyword    string                      // 取消口令，默认"取消排队"
+	PositionKeyword string                      // 查询口令，默认"查询排队"
+	MaxSize         int                         // 队伍最大长度，0不限制
+	MaxPerUser      int                         // 每人最多排队数量，默认1
+	GuardPriority   bool                        // 舰长优先
+	SuperChatJoin   bool                        // 发送醒目留言自动以最高优先级加入，已在队伍中则提升优先级
+	OnChange        func(int, *QueueEvent)      // 队伍变化通知
+	OnPosition      func(int, *QueuePosition)   // 查询位置通知，用于回复用户
+	OnReject        func(int, *MsgModel, error) // 加入失败通知
+}
+
+// QueueEntry 排队项
+type QueueEntry struct {
+	ID       int64     `json:"id"`       // 排队序号
+	UserID   int64     `json:"uid"`      // 用户ID
+	UserName string    `json:"uname"`    // 用户名称
+	Priority int       `json:"priority"` // 优先级
+	Content  string    `json:"content"`  // 口令后面的内容，如歌名
+	JoinTime time.Time `json:"join_time"`
+}
+
+// QueueEvent 队伍变化事件
+type QueueEvent struct {
+	Type    string        // 事件类型
+	Entry   *QueueEntry   // 相关排队项，清空时为nil
+	Entries []*QueueEntry // 变化后的队伍
+}
+
+// QueuePosition 排队位置
+type QueuePosition struct {
+	UserID   int64         // 用户ID
+	UserName string        // 用户名称
+	Entries  []*QueueEntry // 用户的排队项
+	Position []int         // 对应的位置，从1开始
+	Total    int           // 队伍长度
+}
+
+// Queue 观众排队
+type Queue struct {
+	RoomID int         // 房间ID
+	Config QueueConfig // 配置
+
+	sync.Mutex
+	closed  bool
+	nextID  int64
+	entries []*QueueEntry
+	guards  map[int64]bool
+}
+
+func newQueue(roomID int, config QueueConfig) *Queue {
+	if config.JoinKeyword == "" {
+		config.JoinKeyword = "排队"
+	}
+	if config.LeaveKeyword == "" {
+		config.LeaveKeyword = "取消排队"
+	}
+	if config.PositionKeyword == "" {
+		config.PositionKeyword = "查询排队"
+	}
+	if config.MaxPerUser <= 0 {
+		config.MaxPerUser = 1
+	}
+	return &Queue{
+		RoomID: roomID,
+		Config: config,
+		guards: make(map[int64]bool),
+	}
+}
+
+// Join 加入队伍
+func (q *Queue) Join(userID int64, userName string, priority int, content string) (*QueueEntry, error) {
+	q.Lock()
+	entry, err := q.join(userID, userName, priority, content)
+	if err != nil {
+		q.Unlock()
+		return nil, err
+	}
+	event := q.event(QueueEventJoin, entry)
+	q.Unlock()
+	q.notify(event)
+	return entry, nil
+}
+
+// Next 取出队首，队伍为空时返回false
+func (q *Queue) Next() (*QueueEntry, bool) {
+	return q.pop(QueueEventNext)
+}
+
+// Skip 跳过队首
+func (q *Queue) Skip() (*QueueEntry, bool) {
+	return q.pop(QueueEventSkip)
+}
+
+// Remove 移除用户的全部排队项
+func (q *Queue) Remove(userID int64) bool {
+	return q.remove(userID, QueueEventRemove)
+}
+
+// Clear 清空队伍
+func (q *Queue) Clear() {
+	q.Lock()
+	q.entries = nil
+	event := q.event(QueueEventClear, nil)
+	q.Unlock()
+	q.notify(event)
+}
+
+// Close 关闭排队，不再接受加入
+func (q *Queue) Close() {
+	q.Lock()
+	defer q.Unlock()
+	q.closed = true
+}
+
+// Open 重新开放排队
+func (q *Queue) Open() {
+	q.Lock()
+	defer q.Unlock()
+	q.closed = false
+}
+
+// Entries 当前队伍
+func (q *Queue) Entries() []*QueueEntry {
+	q.Lock()
+	defer q.Unlock()
+	return q.snapshot()
+}
+
+// Position 查询用户位置
+func (q *Queue) Position(userID int64) *QueuePosition {
+	q.Lock()
+	defer q.Unlock()
+	result := &QueuePosition{UserID: userID, Total: len(q.entries)}
+	for i, entry := range q.entries {
+		if entry.UserID == userID {
+			e := *entry
+			result.UserName = e.UserName
+			result.Entries = append(result.Entries, &e)
+			result.Position = append(result.Position, i+1)
+		}
+	}
+	return result
+}
+
+// 收到弹幕
+func (q *Queue) onMsg(m *MsgModel) {
+	content := strings.TrimSpace(m.Content)
+	switch {
+	case content == q.Config.LeaveKeyword:
+		q.remove(m.UserID, QueueEventLeave)
+	case content == q.Config.PositionKeyword:
+		if q.Config.OnPosition != nil {
+			position := q.Position(m.UserID)
+			position.UserName = m.UserName
+			q.Config.OnPosition(q.RoomID, position)
+		}
+	case hasKeyword(content, q.Config.JoinKeyword):
+		priority := QueuePriorityNormal
+		q.Lock()
+		if q.Config.GuardPriority && (m.GuardLevel > 0 || q.guards[m.UserID]) {
+			priority = QueuePriorityGuard
+		}
+		q.Unlock()
+		_, err := q.Join(m.UserID, m.UserName, priority, strings.TrimSpace(strings.TrimPrefix(content, q.Config.JoinKeyword)))
+		if err != nil && q.Config.OnReject != nil {
+			q.Config.OnReject(q.RoomID, m, err)
+		}
+	}
+}
+
+// 内容以关键词开头，并且关键词后面为结尾或空格，"排队 备注"算，"排队的人好多"不算
+func hasKeyword(content string, keyword string) bool {
+	if !strings.HasPrefix(content, keyword) {
+		return false
+	}
+	rest := content[len(keyword):]
+	return rest == "" || strings.IndexFunc(rest, isArgSpace) == 0
+}
+
+// 上船
+func (q *Queue) onGuard(m *GuardBuyModel) {
+	if !q.Config.GuardPriority {
+		return
+	}
+	q.Lock()
+	q.guards[m.UserID] = true
+	q.Unlock()
+	q.promote(m.UserID, QueuePriorityGuard)
+}
+
+// 醒目留言
+func (q *Queue) onSuperChat(m *SuperChatMessageModel) {
+	if !q.Config.SuperChatJoin {
+		return
+	}
+	if q.promote(m.UserID, QueuePrioritySuperChat) {
+		return
+	}
+	content := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(m.Message), q.Config.JoinKeyword))
+	_, _ = q.Join(m.UserID, m.UserInfo.UserName, QueuePrioritySuperChat, content)
+}
+
+// 提升用户排队项的优先级，用户不在队伍中时返回false
+func (q *Queue) promote(userID int64, priority int) bool {
+	q.Lock()
+	var promoted *QueueEntry
+	found := false
+	for _, entry := range q.entries {
+		if entry.UserID != userID {
+			continue
+		}
+		found = true
+		if entry.Priority < priority {
+			entry.Priority = priority
+			promoted = entry
+		}
+	}
+	if promoted == nil {
+		q.Unlock()
+		return found
+	}
+	q.sort()
+	event := q.event(QueueEventPriority, promoted)
+	q.Unlock()
+	q.notify(event)
+	return true
+}
+
+// 加入，调用时需要加锁
+func (q *Queue) join(userID int64, userName string, priority int, content string) (*QueueEntry, error) {
+	if q.closed {
+		return nil, ErrQueueClosed
+	}
+	if q.Config.MaxSize > 0 && len(q.entries) >= q.Config.MaxSize {
+		return nil, ErrQueueFull
+	}
+	count := 0
+	for _, entry := range q.entries {
+		if entry.UserID == userID {
+			count++
+		}
+	}
+	if count >= q.Config.MaxPerUser {
+		return nil, ErrQueueUserLimit
+	}
+	q.nextID++
+	entry := &QueueEntry{
+		ID:       q.nextID,
+		UserID:   userID,
+		UserName: userName,
+		Priority: priority,
+		Content:  content,
+		JoinTime: time.Now(),
+	}
+	q.entries = append(q.entries, entry)
+	q.sort()
+	return entry, nil
+}
+
+func (q *Queue) pop(eventType string) (*QueueEntry, bool) {
+	q.Lock()
+	if len(q.entries) == 0 {
+		q.Unlock()
+		return nil, false
+	}
+	entry := q.entries[0]
+	q.entries = q.entries[1:]
+	event := q.event(eventType, entry)
+	q.Unlock()
+	q.notify(event)
+	return entry, true
+}
+
+func (q *Queue) remove(userID int64, eventType string) bool {
+	q.Lock()
+	var removed []*QueueEntry
+	entries := q.entries[:0]
+	for _, entry := range q.entries {
+		if entry.UserID == userID {
+			removed = append(removed, entry)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	q.entries = entries
+	events := make([]*QueueEvent, 0, len(removed))
+	for _, entry := range removed {
+		events = append(events, q.event(eventType, entry))
+	}
+	q.Unlock()
+	for _, event := range events {
+		q.notify(event)
+	}
+	return len(removed) > 0
+}
+
+// 按优先级和加入顺序排序，调用时需要加锁
+func (q *Queue) sort() {
+	sort.SliceStable(q.entries, func(i, j int) bool {
+		if q.entries[i].Priority != q.entries[j].Priority {
+			return q.entries[i].Priority > q.entries[j].Priority
+		}
+		return q.entries[i].ID < q.entries[j].ID
+	})
+}
+
+// 调用时需要加锁
+func (q *Queue) snapshot() []*QueueEntry {
+	result := make([]*QueueEntry, len(q.entries))
+	for i, entry := range q.entries {
+		e := *entry
+		result[i] = &e
+	}
+	return result
+}
+
+// 调用时需要加锁
+func (q *Queue) event(eventType string, entry *QueueEntry) *QueueEvent {
+	event := &QueueEvent{Type: eventType, Entries: q.snapshot()}
+	if entry != nil {
+		e := *entry
+		event.Entry = &e
+	}
+	return event
+}
+
+func (q *Queue) notify(event *QueueEvent) {
+	if q.Config.OnChange != nil {
+		q.Config.OnChange(q.RoomID, event)
+	}
+}
+
+// 排队管理
+type queueManager struct {
+	sync.Mutex
+	rooms map[int]*Queue
+}
+
+func newQueueManager() *queueManager {
+	return &queueManager{
+		rooms: make(map[int]*Queue),
+	}
+}
+
+func (qm *queueManager) get(roomID int) *Queue {
+	qm.Lock()
+	defer qm.Unlock()
+	return qm.rooms[roomID]
+}
+
+func (qm *queueManager) clear(roomID int) {
+	qm.Lock()
+	defer qm.Unlock()
+	delete(qm.rooms, roomID)
+}
+
+// StartQueue 在房间开启排队，每个房间只能有一个队伍
+func (live *Live) StartQueue(roomID int, config QueueConfig) (*Queue, error) {
+	live.queues.Lock()
+	defer live.queues.Unlock()
+	if _, ok := live.queues.rooms[roomID]; ok {
+		return nil, fmt.Errorf("房间 %d 已开启排队", roomID)
+	}
+	q := newQueue(roomID, config)
+	live.queues.rooms[roomID] = q
+	return q, nil
+}
+
+// StopQueue 关闭房间的排队
+func (live *Live) StopQueue(roomID int) {
+	live.queues.clear(roomID)
+}
+
+// Queue 获取房间的队伍
+func (live *Live) Queue(roomID int) *Queue {
+	return live.queues.get(roomID)
+}
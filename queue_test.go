@@ -0,0 +1,17 @@
+package bililive
+
+import "testing"
+
+func TestQueueJoinKeyword(t *testing.T) {
+	q := newQueue(1, QueueConfig{MaxPerUser: 10})
+	for i, content := range []string{"排队", "排队 晴天", "排队　稻香", "排队的人好多", "排队晴天", "取消排队"} {
+		q.onMsg(&MsgModel{UserID: int64(i + 1), Content: content})
+	}
+	entries := q.Entries()
+	if len(entries) != 3 {
+		t.Fatalf("entries %d", len(entries))
+	}
+	if entries[1].Content != "晴天" || entries[2].Content != "稻香" {
+		t.Fatalf("content %q %q", entries[1].Content, entries[2].Content)
+	}
+}
@@ -0,0 +1,509 @@
+package bililive
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const getRoomPlayInfoPath = "/xlive/web-room/v2/index/getRoomPlayInfo"
+
+// 录制默认参数
+const (
+	defaultRecordRetryInterval = 5 * time.Second
+	defaultRecordReadTimeout   = 30 * time.Second
+)
+
+// 时间戳跳变超过该值时认为流不连续，重新计算偏移
+const flvMaxTimestampJump = 10000
+
+// 画质
+const (
+	QualityOriginal = 10000 // 原画
+	QualityBluRay   = 400   // 蓝光
+	QualitySuperHD  = 250   // 超清
+	QualityHD       = 150   // 高清
+	QualitySmooth   = 80    // 流畅
+)
+
+// ErrNoStream 房间未开播或没有可用的直播流
+var ErrNoStream = errors.New("没有可用的直播流")
+
+// 播放信息
+type roomPlayInfoData struct {
+	LiveStatus  int              `json:"live_status"`
+	PlayURLInfo *roomPlayURLInfo `json:"playurl_info"`
+}
+
+type roomPlayURLInfo struct {
+	PlayURL struct {
+		Stream []*roomPlayStream `json:"stream"`
+	} `json:"playurl"`
+}
+
+type roomPlayStream struct {
+	ProtocolName string `json:"protocol_name"`
+	Format       []struct {
+		FormatName string `json:"format_name"`
+		Codec      []struct {
+			CodecName string `json:"codec_name"`
+			CurrentQn int    `json:"current_qn"`
+			AcceptQn  []int  `json:"accept_qn"`
+			BaseURL   string `json:"base_url"`
+			URLInfo   []struct {
+				Host  string `json:"host"`
+				Extra string `json:"extra"`
+			} `json:"url_info"`
+		} `json:"codec"`
+	} `json:"format"`
+}
+
+// PlayURL HTTP-FLV直播流地址
+type PlayURL struct {
+	Quality       int      // 实际画质，请求的画质不可用时会降低
+	AcceptQuality []int    // 可选的画质
+	URLs          []string // 地址，按顺序尝试
+}
+
+// GetPlayURL 获取HTTP-FLV直播流地址，quality为画质，0为原画，高画质可能需要设置Credential
+func (live *Live) GetPlayURL(ctx context.Context, roomID int, quality int) (*PlayURL, error) {
+	if quality <= 0 {
+		quality = QualityOriginal
+	}
+	query := url.Values{
+		"room_id":  {strconv.Itoa(roomID)},
+		"protocol": {"0"},
+		"format":   {"0"},
+		"codec":    {"0"},
+		"qn":       {strconv.Itoa(quality)},
+		"platform": {"web"},
+		"ptype":    {"8"},
+	}
+	data := &roomPlayInfoData{}
+	if _, err := live.apiGet(ctx, getRoomPlayInfoPath, query, data); err != nil {
+		return nil, err
+	}
+	if data.LiveStatus != LiveStatusLive || data.PlayURLInfo == nil {
+		return nil, ErrNoStream
+	}
+	for _, stream := range data.PlayURLInfo.PlayURL.Stream {
+		if stream.ProtocolName != "http_stream" {
+			continue
+		}
+		for _, format := range stream.Format {
+			if format.FormatName != "flv" {
+				continue
+			}
+			for _, codec := range format.Codec {
+				result := &PlayURL{
+					Quality:       codec.CurrentQn,
+					AcceptQuality: codec.AcceptQn,
+				}
+				for _, info := range codec.URLInfo {
+					result.URLs = append(result.URLs, info.Host+codec.BaseURL+info.Extra)
+				}
+				if len(result.URLs) > 0 {
+					return result, nil
+				}
+			}
+		}
+	}
+	return nil, ErrNoStream
+}
+
+// RecordSegment 录制的一个分段文件
+type RecordSegment struct {
+	RoomID    int           // 房间ID
+	SessionID string        // 场次ID，与Session.ID相同
+	Index     int           // 分段序号，从0开始
+	Path      string        // 文件路径
+	Quality   int           // 画质
+	StartTime time.Time     // 开始写入的时间
+	Offset    time.Duration // 相对开播时间的偏移，转换弹幕字幕时设置ASSOptions.Offset为-Offset
+	Duration  time.Duration // 视频时长
+	Size      int64         // 文件大小
+}
+
+// StreamRecorder 直播录制，开播时下载HTTP-FLV直播流，下播时停止
+// 文件名为场次ID（与Session.ID、DanmakuArchiver相同），分段时第二段起加上序号，如 1000-1600000000_1.flv
+// 断线后自动重连，重连后的时间戳接在之前的后面
+// 中途加入正在直播的房间时，需要设置StatusPollInterval或使用Watch才能知道直播状态
+type StreamRecorder struct {
+	Dir           string                    // 保存目录，默认当前目录
+	Quality       int                       // 画质，默认原画
+	MaxSize       int64                     // 分段大小（字节），0不按大小分段
+	MaxDuration   time.Duration             // 分段时长，0不按时长分段
+	RetryInterval time.Duration             // 断线重连间隔，默认5秒
+	ReadTimeout   time.Duration             // 超过该时间没有收到数据时重连，默认30秒
+	OnSegment     func(int, *RecordSegment) // 分段文件写完通知
+	OnError       func(int, error)          // 下载出错通知，出错后会自动重连
+
+	sync.Mutex
+	wg    sync.WaitGroup
+	rooms map[int]context.CancelFunc
+}
+
+// Close 停止所有录制，等待文件写完
+func (r *StreamRecorder) Close() {
+	r.Lock()
+	rooms := r.rooms
+	r.rooms = nil
+	r.Unlock()
+	for _, cancel := range rooms {
+		cancel()
+	}
+	r.wg.Wait()
+}
+
+// Recording 房间是否正在录制
+func (r *StreamRecorder) Recording(roomID int) bool {
+	r.Lock()
+	defer r.Unlock()
+	_, ok := r.rooms[roomID]
+	return ok
+}
+
+// 开播时开始录制，之前的录制会被停止
+func (r *StreamRecorder) start(live *Live, roomID int, status *RoomStatus) {
+	ctx, cancel := context.WithCancel(live.ctx)
+	r.Lock()
+	if r.rooms == nil {
+		r.rooms = make(map[int]context.CancelFunc)
+	}
+	if previous, ok := r.rooms[roomID]; ok {
+		previous()
+	}
+	r.rooms[roomID] = cancel
+	r.wg.Add(1)
+	r.Unlock()
+
+	go func() {
+		defer r.wg.Done()
+		r.record(ctx, live, roomID, status)
+	}()
+}
+
+// 下播时停止录制，不等待文件写完
+func (r *StreamRecorder) stop(roomID int) {
+	r.Lock()
+	cancel, ok := r.rooms[roomID]
+	delete(r.rooms, roomID)
+	r.Unlock()
+	if ok {
+		cancel()
+	}
+}
+
+// 录制直到停止，断线后重连
+func (r *StreamRecorder) record(ctx context.Context, live *Live, roomID int, status *RoomStatus) {
+	w := &flvRecordWriter{
+		recorder:  r,
+		roomID:    roomID,
+		sessionID: sessionID(roomID, status.Room, status.LiveTime),
+		liveTime:  status.LiveTime,
+		lastTS:    -1,
+	}
+	defer w.close()
+
+	retry := r.RetryInterval
+	if retry <= 0 {
+		retry = defaultRecordRetryInterval
+	}
+	for {
+		err := r.download(ctx, live, roomID, w)
+		if ctx.Err() != nil {
+			return
+		}
+		// 直播流正常结束或已经没有直播流时结束当前分段，可能是下播，重连后写入新的分段
+		if err == nil || errors.Is(err, ErrNoStream) {
+			w.close()
+		}
+		if err != nil {
+			if r.OnError != nil {
+				r.OnError(roomID, err)
+			}
+			if live.Debug {
+				log.Println("record err:", err)
+			}
+		}
+		if err := sleepContext(ctx, retry); err != nil {
+			return
+		}
+	}
+}
+
+// 获取地址并下载，直到连接断开
+func (r *StreamRecorder) download(ctx context.Context, live *Live, roomID int, w *flvRecordWriter) error {
+	playURL, err := live.GetPlayURL(ctx, roomID, r.Quality)
+	if err != nil {
+		return err
+	}
+	for _, u := range playURL.URLs {
+		var body io.ReadCloser
+		body, err = r.open(ctx, live, u)
+		if err != nil {
+			continue
+		}
+		w.quality = playURL.Quality
+		err = r.copy(body, w)
+		body.Close()
+		return err
+	}
+	return err
+}
+
+// 请求直播流，直播流不能使用带超时的HTTPClient，超时由ReadTimeout控制
+func (r *StreamRecorder) open(ctx context.Context, live *Live, u string) (io.ReadCloser, error) {
+	client := *live.httpClient()
+	client.Timeout = 0
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("User-Agent", defaultUserAgent)
+	req.Header.Set("Origin", "https://live.bilibili.com")
+	req.Header.Set("Referer", "https://live.bilibili.com/")
+	if live.Credential != nil {
+		req.Header.Set("Cookie", live.Credential.Cookie())
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("请求直播流失败: %s", resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// 读取FLV并写入文件，超过ReadTimeout没有数据时断开
+// 停止录制时请求的ctx被取消，读取会立即返回
+func (r *StreamRecorder) copy(body io.ReadCloser, w *flvRecordWriter) error {
+	timeout := r.ReadTimeout
+	if timeout <= 0 {
+		timeout = defaultRecordReadTimeout
+	}
+	timer := time.AfterFunc(timeout, func() {
+		body.Close()
+	})
+	defer timer.Stop()
+
+	reader := bufio.NewReaderSize(&idleReader{reader: body, timer: timer, timeout: timeout}, 64<<10)
+	if err := readFLVHeader(reader); err != nil {
+		return err
+	}
+	w.reconnect()
+	for {
+		tag, err := readFLVTag(reader)
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if err := w.write(tag); err != nil {
+			return err
+		}
+	}
+}
+
+// 每次读到数据时重置超时
+type idleReader struct {
+	reader  io.Reader
+	timer   *time.Timer
+	timeout time.Duration
+}
+
+func (r *idleReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if n > 0 {
+		r.timer.Reset(r.timeout)
+	}
+	return n, err
+}
+
+// 写入录制文件，处理分段和时间戳
+type flvRecordWriter struct {
+	recorder  *StreamRecorder
+	roomID    int
+	sessionID string
+	liveTime  time.Time
+	quality   int
+
+	file    *os.File
+	writer  *bufio.Writer
+	segment *RecordSegment
+	index   int
+
+	metadata    *flvTag // 最近的onMetaData
+	videoHeader *flvTag // 最近的视频解码参数
+	audioHeader *flvTag // 最近的音频解码参数
+	hasVideo    bool
+
+	// 时间戳修正，输出的时间戳 = 输入的时间戳 + offset，在整场直播中连续
+	rebase      bool // 下一个音视频tag需要重新计算offset
+	offset      int64
+	lastTS      int64 // 最大的输出时间戳，-1为还没有
+	segmentBase int64 // 当前分段开始的输出时间戳
+}
+
+// 新的连接，时间戳会从新的起点开始
+func (w *flvRecordWriter) reconnect() {
+	w.rebase = true
+}
+
+func (w *flvRecordWriter) write(tag *flvTag) error {
+	switch {
+	case tag.Type == flvTagScript:
+		// 只保留最近的onMetaData，在新分段开头写入
+		w.metadata = tag
+		return nil
+	case tag.isSequenceHeader():
+		previous := w.audioHeader
+		if tag.Type == flvTagVideo {
+			previous = w.videoHeader
+			w.videoHeader = tag
+		} else {
+			w.audioHeader = tag
+		}
+		// 重连后解码参数相同时不重复写入
+		if w.file == nil || previous != nil && string(previous.Data) == string(tag.Data) {
+			return nil
+		}
+		return w.writeTag(tag, w.lastTS)
+	case tag.Type != flvTagVideo && tag.Type != flvTagAudio:
+		return nil
+	}
+	if tag.Type == flvTagVideo {
+		w.hasVideo = true
+	}
+
+	ts := tag.Timestamp + w.offset
+	if w.rebase || w.lastTS >= 0 && (ts < w.lastTS-flvMaxTimestampJump || ts > w.lastTS+flvMaxTimestampJump) {
+		// 新连接或流中的时间戳跳变，接在之前的时间戳后面
+		w.offset = w.lastTS + 1 - tag.Timestamp
+		if w.lastTS < 0 {
+			w.offset = -tag.Timestamp
+		}
+		w.rebase = false
+		ts = tag.Timestamp + w.offset
+	}
+
+	// 分段只在关键帧处切开，没有视频时可以在任意位置切开
+	if w.file != nil && (tag.isKeyframe() || !w.hasVideo) {
+		r := w.recorder
+		if r.MaxSize > 0 && w.segment.Size >= r.MaxSize ||
+			r.MaxDuration > 0 && time.Duration(ts-w.segmentBase)*time.Millisecond >= r.MaxDuration {
+			w.close()
+		}
+	}
+	if w.file == nil {
+		if err := w.open(ts); err != nil {
+			return err
+		}
+	}
+	if err := w.writeTag(tag, ts); err != nil {
+		return err
+	}
+	if ts > w.lastTS {
+		w.lastTS = ts
+	}
+	return nil
+}
+
+// 写入tag，时间戳为相对分段开头的时间
+func (w *flvRecordWriter) writeTag(tag *flvTag, ts int64) error {
+	ts -= w.segmentBase
+	if ts < 0 {
+		ts = 0
+	}
+	n, err := writeFLVTag(w.writer, tag, ts)
+	w.segment.Size += int64(n)
+	if d := time.Duration(ts) * time.Millisecond; d > w.segment.Duration {
+		w.segment.Duration = d
+	}
+	return err
+}
+
+// 新建分段文件，写入文件头、onMetaData和解码参数，已存在的文件不会被覆盖
+func (w *flvRecordWriter) open(ts int64) error {
+	r := w.recorder
+	var (
+		file *os.File
+		path string
+		err  error
+	)
+	for {
+		name := w.sessionID + ".flv"
+		if w.index > 0 {
+			name = fmt.Sprintf("%s_%d.flv", w.sessionID, w.index)
+		}
+		path = filepath.Join(r.Dir, name)
+		file, err = os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0644)
+		if !os.IsExist(err) {
+			break
+		}
+		w.index++
+	}
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	w.file = file
+	w.writer = bufio.NewWriterSize(file, 256<<10)
+	w.segmentBase = ts
+	w.segment = &RecordSegment{
+		RoomID:    w.roomID,
+		SessionID: w.sessionID,
+		Index:     w.index,
+		Path:      path,
+		Quality:   w.quality,
+		StartTime: now,
+		Offset:    now.Sub(w.liveTime),
+		Size:      int64(len(flvHeader)),
+	}
+	if _, err := w.writer.Write(flvHeader); err != nil {
+		return err
+	}
+	for _, tag := range []*flvTag{w.metadata, w.videoHeader, w.audioHeader} {
+		if tag == nil {
+			continue
+		}
+		if err := w.writeTag(tag, ts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 结束当前分段
+func (w *flvRecordWriter) close() {
+	if w.file == nil {
+		return
+	}
+	err := w.writer.Flush()
+	if closeErr := w.file.Close(); err == nil {
+		err = closeErr
+	}
+	segment := w.segment
+	w.file, w.writer, w.segment = nil, nil, nil
+	w.index++
+	if err != nil && w.recorder.OnError != nil {
+		w.recorder.OnError(w.roomID, err)
+	}
+	if w.recorder.OnSegment != nil {
+		w.recorder.OnSegment(w.roomID, segment)
+	}
+}
@@ -0,0 +1,366 @@
+package bililive
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const (
+	testFrameInterval = 40 // 毫秒
+	testGOP           = 10 // 每10帧一个关键帧
+)
+
+// 写入FLV头、onMetaData、解码参数和pairs组音视频帧，时间戳从base开始
+func writeTestFLV(w io.Writer, base int64, pairs int) {
+	w.Write(flvHeader)
+	writeFLVTag(w, &flvTag{Type: flvTagScript, Data: []byte("onMetaData")}, 0)
+	writeFLVTag(w, &flvTag{Type: flvTagVideo, Data: []byte{0x17, 0, 0, 0, 0, 1}}, 0)
+	writeFLVTag(w, &flvTag{Type: flvTagAudio, Data: []byte{0xAF, 0, 0x12, 0x10}}, 0)
+	for i := 0; i < pairs; i++ {
+		writeTestFrame(w, base, i)
+	}
+}
+
+func writeTestFrame(w io.Writer, base int64, i int) {
+	frame := byte(0x27)
+	if i%testGOP == 0 {
+		frame = 0x17
+	}
+	ts := base + int64(i*testFrameInterval)
+	video := append([]byte{frame, 1, 0, 0, 0}, make([]byte, 200)...)
+	writeFLVTag(w, &flvTag{Type: flvTagVideo, Data: video}, ts)
+	writeFLVTag(w, &flvTag{Type: flvTagAudio, Data: []byte{0xAF, 1, 0x21, 0x10}}, ts+5)
+}
+
+// 本地直播服务，stream按连接序号（从1开始）写入直播流，live为false时播放信息返回未开播
+type recordServer struct {
+	live   int32
+	conns  int32
+	stream func(w http.ResponseWriter, r *http.Request, n int)
+}
+
+func newRecordTest(t *testing.T, s *recordServer) (*Live, context.CancelFunc) {
+	atomic.StoreInt32(&s.live, 1)
+	mux := http.NewServeMux()
+	var srv *httptest.Server
+	mux.HandleFunc(getRoomPlayInfoPath, func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&s.live) == 0 {
+			writeTestAPI(w, 0, "", &roomPlayInfoData{LiveStatus: LiveStatusPreparing})
+			return
+		}
+		fmt.Fprintf(w, `{"code":0,"data":{"live_status":1,"playurl_info":{"playurl":{"stream":[`+
+			`{"protocol_name":"http_hls","format":[{"format_name":"ts","codec":[{"codec_name":"avc","current_qn":10000,"base_url":"/hls.m3u8","url_info":[{"host":"%[1]s","extra":""}]}]}]},`+
+			`{"protocol_name":"http_stream","format":[{"format_name":"flv","codec":[{"codec_name":"avc","current_qn":%[2]s,"accept_qn":[10000,150],"base_url":"/live/1000.flv","url_info":[{"host":"%[1]s","extra":"?expires=1"}]}]}]}]}}}}`,
+			srv.URL, r.URL.Query().Get("qn"))
+	})
+	mux.HandleFunc("/live/1000.flv", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("expires") != "1" || r.Header.Get("Referer") == "" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		s.stream(w, r, int(atomic.AddInt32(&s.conns, 1)))
+	})
+	srv = httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	ctx, cancel := context.WithCancel(context.Background())
+	live := &Live{
+		APIBaseURL: srv.URL,
+		HTTPClient: srv.Client(),
+		ctx:        ctx,
+		statuses:   newStatusTracker(),
+	}
+	return live, cancel
+}
+
+// 录制结果
+type recordResult struct {
+	sync.Mutex
+	segments []*RecordSegment
+	errs     []error
+}
+
+func (res *recordResult) recorder(dir string) *StreamRecorder {
+	return &StreamRecorder{
+		Dir:           dir,
+		Quality:       QualityHD,
+		RetryInterval: 10 * time.Millisecond,
+		ReadTimeout:   time.Second,
+		OnSegment: func(_ int, s *RecordSegment) {
+			res.Lock()
+			res.segments = append(res.segments, s)
+			res.Unlock()
+		},
+		OnError: func(_ int, err error) {
+			res.Lock()
+			res.errs = append(res.errs, err)
+			res.Unlock()
+		},
+	}
+}
+
+func (res *recordResult) wait(t *testing.T, f func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		res.Lock()
+		ok := f()
+		res.Unlock()
+		if ok {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("timeout")
+}
+
+// 读取录制的文件，返回音视频tag
+func readRecorded(t *testing.T, path string) []*flvTag {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	if err := readFLVHeader(r); err != nil {
+		t.Fatal(err)
+	}
+	var result []*flvTag
+	headers := 0
+	for {
+		tag, err := readFLVTag(r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		// 每个分段开头都有onMetaData和解码参数
+		if len(result) == 0 && (tag.Type == flvTagScript || tag.isSequenceHeader()) {
+			if tag.Timestamp != 0 {
+				t.Fatalf("%s: header tag at %d", path, tag.Timestamp)
+			}
+			headers++
+			continue
+		}
+		result = append(result, tag)
+	}
+	if headers != 3 {
+		t.Fatalf("%s: %d header tags", path, headers)
+	}
+	if len(result) == 0 || !result[0].isKeyframe() {
+		t.Fatalf("%s: segment does not start with a keyframe", path)
+	}
+	return result
+}
+
+func segmentName(sessionID string, index int) string {
+	if index == 0 {
+		return sessionID + ".flv"
+	}
+	return fmt.Sprintf("%s_%d.flv", sessionID, index)
+}
+
+func TestRecorderReconnectAndSegments(t *testing.T) {
+	const pairs = 60
+	server := &recordServer{}
+	server.stream = func(w http.ResponseWriter, r *http.Request, n int) {
+		switch n {
+		case 1:
+			// 写到一半断开
+			bw := bufio.NewWriter(w)
+			writeTestFLV(bw, 100000, pairs)
+			bw.Write([]byte{flvTagVideo, 0, 1, 0})
+			bw.Flush()
+		case 2:
+			// 重连后时间戳从0开始，正常结束
+			writeTestFLV(w, 0, pairs)
+			atomic.StoreInt32(&server.live, 0)
+		default:
+			http.NotFound(w, r)
+		}
+	}
+	live, cancel := newRecordTest(t, server)
+	defer cancel()
+	dir, err := ioutil.TempDir("", "record")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	res := &recordResult{}
+	recorder := res.recorder(dir)
+	recorder.MaxDuration = time.Second
+	live.Recorder = recorder
+	room := &Room{RoomID: 3, RealRoomID: 1000, ShortID: 3}
+	start := time.Now().Add(-time.Minute).Truncate(time.Second)
+	live.setStatus(3, room, statusUpdate{liveStatus: LiveStatusLive, liveTime: start, source: StatusSourcePush})
+
+	// 第二个直播流结束后当前分段被关闭，之后获取地址返回ErrNoStream
+	res.wait(t, func() bool {
+		for _, err := range res.errs {
+			if errors.Is(err, ErrNoStream) {
+				return true
+			}
+		}
+		return false
+	})
+	live.setStatus(3, room, statusUpdate{liveStatus: LiveStatusPreparing, reason: EndReasonNormal, source: StatusSourcePush})
+	recorder.Close()
+	if recorder.Recording(3) {
+		t.Fatal("still recording")
+	}
+
+	id := sessionID(3, room, start)
+	var (
+		last   int64 = -1
+		frames int
+	)
+	for i, segment := range res.segments {
+		if segment.SessionID != id || segment.Index != i || segment.Quality != QualityHD {
+			t.Fatalf("segment %d: %+v", i, segment)
+		}
+		if want := filepath.Join(dir, segmentName(id, i)); segment.Path != want {
+			t.Fatalf("segment %d path %s, want %s", i, segment.Path, want)
+		}
+		if info, err := os.Stat(segment.Path); err != nil || info.Size() != segment.Size {
+			t.Fatalf("segment %d size %d: %v", i, segment.Size, err)
+		}
+		// 按时长分段，在超过时长后的第一个关键帧切开
+		if max := time.Second + testGOP*testFrameInterval*time.Millisecond; segment.Duration > max {
+			t.Fatalf("segment %d duration %v", i, segment.Duration)
+		}
+		var previous int64 = -1
+		for _, tag := range readRecorded(t, segment.Path) {
+			if tag.Timestamp < previous {
+				t.Fatalf("segment %d: timestamp %d after %d", i, tag.Timestamp, previous)
+			}
+			// 重连前后的时间戳是连续的，不会跳到100000或回到0
+			if previous >= 0 && tag.Timestamp-previous > testFrameInterval {
+				t.Fatalf("segment %d: timestamp jump %d -> %d", i, previous, tag.Timestamp)
+			}
+			previous = tag.Timestamp
+			if tag.Type == flvTagVideo {
+				frames++
+			}
+		}
+		last = previous
+	}
+	if len(res.segments) < 4 || last < 0 {
+		t.Fatalf("%d segments", len(res.segments))
+	}
+	// 断开时的半个tag被丢弃
+	if frames != 2*pairs {
+		t.Fatalf("%d video frames", frames)
+	}
+}
+
+func TestRecorderMaxSizeAndExistingFiles(t *testing.T) {
+	server := &recordServer{}
+	server.stream = func(w http.ResponseWriter, r *http.Request, n int) {
+		if n > 1 {
+			http.NotFound(w, r)
+			return
+		}
+		writeTestFLV(w, 5000, 50)
+		atomic.StoreInt32(&server.live, 0)
+	}
+	live, cancel := newRecordTest(t, server)
+	defer cancel()
+	dir, err := ioutil.TempDir("", "record")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	room := &Room{RoomID: 1000, RealRoomID: 1000}
+	start := time.Now().Truncate(time.Second)
+	id := sessionID(1000, room, start)
+	// 已经存在的文件不会被覆盖
+	existing := filepath.Join(dir, segmentName(id, 0))
+	if err := ioutil.WriteFile(existing, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	res := &recordResult{}
+	recorder := res.recorder(dir)
+	recorder.MaxSize = 2000
+	recorder.start(live, 1000, &RoomStatus{RoomID: 1000, Room: room, LiveTime: start})
+	res.wait(t, func() bool { return len(res.errs) > 0 })
+	recorder.Close()
+
+	if data, _ := ioutil.ReadFile(existing); string(data) != "old" {
+		t.Fatal("existing file overwritten")
+	}
+	if len(res.segments) != 5 {
+		t.Fatalf("%d segments", len(res.segments))
+	}
+	for i, segment := range res.segments {
+		if want := filepath.Join(dir, segmentName(id, i+1)); segment.Path != want {
+			t.Fatalf("segment %d path %s, want %s", i, segment.Path, want)
+		}
+		tags := readRecorded(t, segment.Path)
+		if tags[0].Timestamp != 0 {
+			t.Fatalf("segment %d starts at %d", i, tags[0].Timestamp)
+		}
+		// 每段10帧，超过大小后在下一个关键帧切开
+		if len(tags) != 2*testGOP {
+			t.Fatalf("segment %d: %d tags", i, len(tags))
+		}
+	}
+}
+
+func TestRecorderStopsOnEnd(t *testing.T) {
+	server := &recordServer{}
+	server.stream = func(w http.ResponseWriter, r *http.Request, n int) {
+		writeTestFLV(w, 0, 0)
+		for i := 0; ; i++ {
+			writeTestFrame(w, 0, i)
+			w.(http.Flusher).Flush()
+			select {
+			case <-r.Context().Done():
+				return
+			case <-time.After(5 * time.Millisecond):
+			}
+		}
+	}
+	live, cancel := newRecordTest(t, server)
+	defer cancel()
+	dir, err := ioutil.TempDir("", "record")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	res := &recordResult{}
+	live.Recorder = res.recorder(dir)
+	room := &Room{RoomID: 1000, RealRoomID: 1000}
+	live.setStatus(1000, room, statusUpdate{liveStatus: LiveStatusLive, source: StatusSourcePush})
+	time.Sleep(100 * time.Millisecond)
+	live.setStatus(1000, room, statusUpdate{liveStatus: LiveStatusPreparing, reason: EndReasonNormal, source: StatusSourcePush})
+
+	// 下播后不需要Close或再次开播，分段就会写完
+	res.wait(t, func() bool { return len(res.segments) == 1 })
+	if live.Recorder.Recording(1000) {
+		t.Fatal("still recording")
+	}
+	if tags := readRecorded(t, res.segments[0].Path); len(tags) == 0 {
+		t.Fatal("empty segment")
+	}
+	live.Recorder.Close()
+	if len(res.errs) != 0 || atomic.LoadInt32(&server.conns) != 1 {
+		t.Fatalf("errors %v, connections %d", res.errs, server.conns)
+	}
+}
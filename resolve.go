@@ -0,0 +1,163 @@
+package bililive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	roomInfoOldPath  = "/room/v1/Room/getRoomInfoOld"
+	maxResolveRedirs = 10
+)
+
+// 解析错误
+var (
+	ErrUnknownInput = errors.New("无法识别的房间地址")
+	ErrNoLiveRoom   = errors.New("该用户没有开通直播间")
+)
+
+// 只跟随这些域名的跳转，避免把用户输入的任意地址交给HTTPClient请求
+var redirectHosts = []string{"b23.tv", "bili2233.cn", "bilibili.com"}
+
+// 按UID查询直播间
+type roomInfoOldData struct {
+	RoomID int `json:"roomid"`
+}
+
+// ResolveInput 把用户输入解析为房间，支持：
+//
+//	房间号：123
+//	直播间地址：https://live.bilibili.com/123?xxx、live.bilibili.com/blanc/123、live.bilibili.com/h5/123
+//	主播UID：uid:123、space.bilibili.com/123
+//	短链接：https://b23.tv/xxx，以及其他跳转到以上地址的链接
+//
+// 短链接通过HTTPClient跟随跳转，只请求b23.tv、bili2233.cn和bilibili.com的地址，其他地址返回ErrUnknownInput
+func (live *Live) ResolveInput(ctx context.Context, input string) (*Room, error) {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return nil, ErrUnknownInput
+	}
+	if id, err := strconv.Atoi(input); err == nil && id > 0 {
+		return live.resolveRoom(ctx, id)
+	}
+	lower := strings.ToLower(input)
+	for _, prefix := range []string{"uid:", "uid："} {
+		if strings.HasPrefix(lower, prefix) {
+			uid, err := strconv.ParseInt(strings.TrimSpace(input[len(prefix):]), 10, 64)
+			if err != nil || uid <= 0 {
+				return nil, ErrUnknownInput
+			}
+			return live.ResolveUID(ctx, uid)
+		}
+	}
+
+	if !strings.Contains(input, "://") {
+		input = "https://" + input
+	}
+	u, err := url.Parse(input)
+	if err != nil {
+		return nil, ErrUnknownInput
+	}
+	for i := 0; i < maxResolveRedirs; i++ {
+		if room, ok, err := live.resolveURL(ctx, u); ok {
+			return room, err
+		}
+		if !isRedirectHost(u) {
+			return nil, ErrUnknownInput
+		}
+		next, err := live.redirect(ctx, u)
+		if err != nil {
+			return nil, err
+		}
+		u = next
+	}
+	return nil, fmt.Errorf("跳转次数过多: %w", ErrUnknownInput)
+}
+
+// ResolveUID 按主播UID获取直播间
+func (live *Live) ResolveUID(ctx context.Context, uid int64) (*Room, error) {
+	data := &roomInfoOldData{}
+	if _, err := live.apiGet(ctx, roomInfoOldPath, url.Values{"mid": {strconv.FormatInt(uid, 10)}}, data); err != nil {
+		return nil, err
+	}
+	if data.RoomID == 0 {
+		return nil, ErrNoLiveRoom
+	}
+	return live.resolveRoom(ctx, data.RoomID)
+}
+
+// 解析已知的地址，不是已知地址时返回false
+func (live *Live) resolveURL(ctx context.Context, u *url.URL) (*Room, bool, error) {
+	host := strings.ToLower(u.Hostname())
+	switch {
+	case host == "live.bilibili.com":
+		id := lastNumericSegment(u.Path)
+		if id <= 0 {
+			return nil, true, ErrUnknownInput
+		}
+		room, err := live.resolveRoom(ctx, int(id))
+		return room, true, err
+	case host == "space.bilibili.com" || host == "m.bilibili.com" && strings.HasPrefix(u.Path, "/space/"):
+		uid := lastNumericSegment(u.Path)
+		if uid <= 0 {
+			return nil, true, ErrUnknownInput
+		}
+		room, err := live.ResolveUID(ctx, uid)
+		return room, true, err
+	}
+	return nil, false, nil
+}
+
+// 请求链接，返回跳转地址
+func (live *Live) redirect(ctx context.Context, u *url.URL) (*url.URL, error) {
+	client := *live.httpClient()
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("User-Agent", defaultUserAgent)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	location, err := resp.Location()
+	if err != nil {
+		return nil, ErrUnknownInput
+	}
+	return location, nil
+}
+
+// 是否为可以跟随跳转的短链接域名，包括子域名
+func isRedirectHost(u *url.URL) bool {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, allowed := range redirectHosts {
+		if host == allowed || strings.HasSuffix(host, "."+allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// 路径中最后一个数字段
+func lastNumericSegment(path string) int64 {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for i := len(segments) - 1; i >= 0; i-- {
+		if id, err := strconv.ParseInt(segments[i], 10, 64); err == nil {
+			return id
+		}
+	}
+	return 0
+}
@@ -0,0 +1,102 @@
+package bililive
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// 所有域名都连接到本地服务，按Host区分短链接
+func newResolveTest(t *testing.T) (*Live, *[]string) {
+	var (
+		lock      sync.Mutex
+		requested []string
+	)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		requested = append(requested, r.Host+r.URL.Path)
+		lock.Unlock()
+		switch r.Host + r.URL.Path {
+		case "b23.tv/abc":
+			http.Redirect(w, r, "http://bili2233.cn/xyz", http.StatusFound)
+		case "bili2233.cn/xyz":
+			http.Redirect(w, r, "https://live.bilibili.com/h5/3?share_source=copy_link", http.StatusFound)
+		case "b23.tv/evil":
+			http.Redirect(w, r, "http://evil.example/next", http.StatusFound)
+		case "b23.tv/loop":
+			http.Redirect(w, r, "http://b23.tv/loop", http.StatusFound)
+		case "b23.tv/none":
+			w.WriteHeader(http.StatusOK)
+		default:
+			switch r.URL.Path {
+			case roomInitPath:
+				writeTestAPI(w, 0, "", &roomInfoData{RoomID: 23058, ShortID: 3})
+			case roomInfoOldPath:
+				writeTestAPI(w, 0, "", &roomInfoOldData{RoomID: 3})
+			default:
+				http.NotFound(w, r)
+			}
+		}
+	})
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	addr := srv.Listener.Addr().String()
+	live := &Live{
+		APIBaseURL: srv.URL,
+		HTTPClient: &http.Client{Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, network, addr)
+			},
+		}},
+	}
+	return live, &requested
+}
+
+func TestResolveInput(t *testing.T) {
+	live, _ := newResolveTest(t)
+	for _, input := range []string{
+		"3",
+		"https://live.bilibili.com/3?visit_id=x",
+		"live.bilibili.com/blanc/3",
+		"uid:2",
+		"UID：2",
+		"https://space.bilibili.com/2/dynamic",
+		"http://b23.tv/abc",
+	} {
+		room, err := live.ResolveInput(context.Background(), input)
+		if err != nil {
+			t.Fatalf("%q: %v", input, err)
+		}
+		if room.RealRoomID != 23058 || room.ShortID != 3 {
+			t.Fatalf("%q: %+v", input, room)
+		}
+	}
+}
+
+func TestResolveInputRejectsOtherHosts(t *testing.T) {
+	live, requested := newResolveTest(t)
+	for _, input := range []string{
+		"http://evil.example/abc",
+		"http://127.0.0.1/abc",
+		"http://b23.tv.evil.example/abc",
+		"ftp://b23.tv/abc",
+		"http://b23.tv/evil",
+		"http://b23.tv/none",
+		"http://b23.tv/loop",
+		"uid:abc",
+		"",
+	} {
+		if _, err := live.ResolveInput(context.Background(), input); !errors.Is(err, ErrUnknownInput) {
+			t.Fatalf("%q: %v", input, err)
+		}
+	}
+	for _, r := range *requested {
+		if r != "b23.tv/evil" && r != "b23.tv/none" && r != "b23.tv/loop" {
+			t.Fatalf("requested %s", r)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package bililive
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	roomGetInfoPath    = "/room/v1/Room/get_info"
+	anchorInfoPath     = "/live_user/v1/Master/info"
+	defaultRoomInfoTTL = time.Minute
+)
+
+// 直播状态
+const (
+	LiveStatusPreparing = 0 // 未开播
+	LiveStatusLive      = 1 // 直播中
+	LiveStatusRound     = 2 // 轮播中
+)
+
+// RoomInfo 直播间信息
+type RoomInfo struct {
+	RoomID         int       `json:"room_id"`          // 真实房间ID
+	ShortID        int       `json:"short_id"`         // 短号，没有时为0
+	Title          string    `json:"title"`            // 标题
+	Cover          string    `json:"cover"`            // 封面
+	Keyframe       string    `json:"keyframe"`         // 关键帧
+	Description    string    `json:"description"`      // 简介
+	AreaID         int       `json:"area_id"`          // 分区ID
+	AreaName       string    `json:"area_name"`        // 分区名称
+	ParentAreaID   int       `json:"parent_area_id"`   // 父分区ID
+	ParentAreaName string    `json:"parent_area_name"` // 父分区名称
+	AnchorID       int64     `json:"anchor_uid"`       // 主播ID
+	AnchorName     string    `json:"anchor_name"`      // 主播名称
+	AnchorFace     string    `json:"anchor_face"`      // 主播头像
+	LiveStatus     int       `json:"live_status"`      // 直播状态
+	LiveTime       time.Time `json:"live_time"`        // 开播时间，未开播时为零值
+	Online         int       `json:"online"`           // 在线人数（人气值）
+	Tags           []string  `json:"tags"`             // 标签
+	Locked         bool      `json:"locked"`           // 是否被封禁
+	Encrypted      bool      `json:"encrypted"`        // 是否加密
+	Hidden         bool      `json:"hidden"`           // 是否隐藏
+	FetchTime      time.Time `json:"fetch_time"`       // 获取时间
+}
+
+// IsLive 是否正在直播，轮播不算
+func (info *RoomInfo) IsLive() bool {
+	return info.LiveStatus == LiveStatusLive
+}
+
+// 房间详情
+type roomDetailData struct {
+	Title          string `json:"title"`
+	UserCover      string `json:"user_cover"`
+	Keyframe       string `json:"keyframe"`
+	Description    string `json:"description"`
+	AreaID         int    `json:"area_id"`
+	AreaName       string `json:"area_name"`
+	ParentAreaID   int    `json:"parent_area_id"`
+	ParentAreaName string `json:"parent_area_name"`
+	Online         int    `json:"online"`
+	Tags           string `json:"tags"`
+}
+
+// 主播信息
+type anchorInfoData struct {
+	Info struct {
+		UID   int64  `json:"uid"`
+		UName string `json:"uname"`
+		Face  string `json:"face"`
+	} `json:"info"`
+}
+
+// 房间信息缓存，短号和真实ID都指向同一条记录
+type roomInfoCache struct {
+	sync.Mutex
+	rooms map[int]*RoomInfo
+}
+
+func (c *roomInfoCache) get(roomID int, ttl time.Duration) *RoomInfo {
+	c.Lock()
+	defer c.Unlock()
+	info, ok := c.rooms[roomID]
+	if !ok || time.Since(info.FetchTime) > ttl {
+		return nil
+	}
+	return info
+}
+
+// 查找缓存，不判断是否过期，用于短号转换
+func (c *roomInfoCache) lookup(roomID int) *RoomInfo {
+	c.Lock()
+	defer c.Unlock()
+	return c.rooms[roomID]
+}
+
+func (c *roomInfoCache) set(info *RoomInfo, roomIDs ...int) {
+	c.Lock()
+	defer c.Unlock()
+	if c.rooms == nil {
+		c.rooms = make(map[int]*RoomInfo)
+	}
+	for _, id := range append(roomIDs, info.RoomID, info.ShortID) {
+		if id > 0 {
+			c.rooms[id] = info
+		}
+	}
+}
+
+func (c *roomInfoCache) invalidate(roomID int) {
+	c.Lock()
+	defer c.Unlock()
+	info, ok := c.rooms[roomID]
+	if !ok {
+		return
+	}
+	for id, i := range c.rooms {
+		if i == info {
+			delete(c.rooms, id)
+		}
+	}
+}
+
+// GetRoomInfo 获取直播间信息，支持短号，结果按RoomInfoTTL缓存
+// 返回的RoomInfo为缓存共享，不要修改
+func (live *Live) GetRoomInfo(ctx context.Context, roomID int) (*RoomInfo, error) {
+	ttl := live.RoomInfoTTL
+	if ttl <= 0 {
+		ttl = defaultRoomInfoTTL
+	}
+	if info := live.roomInfos.get(roomID, ttl); info != nil {
+		return info, nil
+	}
+	info, err := live.fetchRoomInfo(ctx, roomID)
+	if err != nil {
+		return nil, err
+	}
+	live.roomInfos.set(info, roomID)
+	return info, nil
+}
+
+// InvalidateRoomInfo 清除房间信息缓存，下次GetRoomInfo时重新获取
+func (live *Live) InvalidateRoomInfo(roomID int) {
+	live.roomInfos.invalidate(roomID)
+}
+
+// ResolveRoom 获取房间ID记录，支持短号
+// 已加入或已缓存信息的房间不再请求接口
+func (live *Live) ResolveRoom(roomID int) (*Room, error) {
+	ctx := live.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return live.resolveRoom(ctx, roomID)
+}
+
+func (live *Live) resolveRoom(ctx context.Context, roomID int) (*Room, error) {
+	live.roomLock.Lock()
+	room, ok := live.room[roomID]
+	live.roomLock.Unlock()
+	if ok {
+		if info := room.getInfo(); info != nil {
+			r := *info
+			return &r, nil
+		}
+	}
+	if info := live.roomInfos.lookup(roomID); info != nil {
+		return &Room{RoomID: roomID, RealRoomID: info.RoomID, ShortID: info.ShortID}, nil
+	}
+	base := &roomInfoData{}
+	if _, err := live.apiGet(ctx, roomInitPath, url.Values{"id": {strconv.Itoa(roomID)}}, base); err != nil {
+		return nil, err
+	}
+	return &Room{RoomID: roomID, RealRoomID: base.RoomID, ShortID: base.ShortID}, nil
+}
+
+func (live *Live) fetchRoomInfo(ctx context.Context, roomID int) (*RoomInfo, error) {
+	base := &roomInfoData{}
+	if _, err := live.apiGet(ctx, roomInitPath, url.Values{"id": {strconv.Itoa(roomID)}}, base); err != nil {
+		return nil, err
+	}
+	detail := &roomDetailData{}
+	if _, err := live.apiGet(ctx, roomGetInfoPath, url.Values{"room_id": {strconv.Itoa(base.RoomID)}}, detail); err != nil {
+		return nil, err
+	}
+	anchor := &anchorInfoData{}
+	if _, err := live.apiGet(ctx, anchorInfoPath, url.Values{"uid": {strconv.FormatInt(base.UID, 10)}}, anchor); err != nil {
+		return nil, err
+	}
+
+	info := &RoomInfo{
+		RoomID:         base.RoomID,
+		ShortID:        base.ShortID,
+		Title:          detail.Title,
+		Cover:          detail.UserCover,
+		Keyframe:       detail.Keyframe,
+		Description:    detail.Description,
+		AreaID:         detail.AreaID,
+		AreaName:       detail.AreaName,
+		ParentAreaID:   detail.ParentAreaID,
+		ParentAreaName: detail.ParentAreaName,
+		AnchorID:       base.UID,
+		AnchorName:     anchor.Info.UName,
+		AnchorFace:     anchor.Info.Face,
+		LiveStatus:     base.LiveStatus,
+		Online:         detail.Online,
+		Locked:         base.IsLocked,
+		Encrypted:      base.Encrypted,
+		Hidden:         base.IsHidden,
+		FetchTime:      time.Now(),
+	}
+	if base.LiveTime > 0 {
+		info.LiveTime = time.Unix(base.LiveTime, 0)
+	}
+	for _, tag := range strings.Split(detail.Tags, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			info.Tags = append(info.Tags, tag)
+		}
+	}
+	return info, nil
+}
@@ -12,14 +12,15 @@ import (
 	"log"
 	"math/rand"
 	"net"
+	"net/url"
 	"strconv"
 	"sync"
 	"time"
 )
 
 const (
-	roomInitURL                    string = "https://api.live.bilibili.com/room/v1/Room/room_init?id=%d"
-	roomConfigURL                  string = "https://api.live.bilibili.com/room/v1/Danmu/getConf?room_id=%d"
+	roomInitPath                   string = "/room/v1/Room/room_init"
+	roomConfigPath                 string = "/room/v1/Danmu/getConf"
 	WS_OP_HEARTBEAT                int32  = 2
 	WS_OP_HEARTBEAT_REPLY          int32  = 3
 	WS_OP_MESSAGE                  int32  = 5
@@ -50,15 +51,48 @@ func (live *Live) Start(ctx context.Context) {
 	}
 
 	live.room = make(map[int]*liveRoom)
+	live.superChats = newSuperChatCache()
+	live.lotteries = newLotteryManager()
+	live.polls = newPollManager()
+	live.queues = newQueueManager()
+	live.statuses = newStatusTracker()
+	live.watcher = newRoomWatcher()
 	live.chSocketMessage = make(chan *socketMessage, 30)
 	live.chOperation = make(chan *operateInfo, 300)
-	if live.StormFilter && live.ReceiveMsg != nil {
-		live.storming = make(map[int]bool)
-		live.stormContent = make(map[int]map[int64]string)
+	live.storms = newStormTracker()
+	var filters []Filter
+	if live.StormFilter {
+		filters = append(filters, FilterFunc(live.stormFilter))
+	}
+	if live.SpamDetector != nil {
+		if live.SpamDetector.OnWave == nil {
+			live.SpamDetector.OnWave = live.SpamWave
+		}
+		filters = append(filters, live.SpamDetector)
+	}
+	live.filters = newFilterChain(append(filters, live.Filters...))
+
+	if live.RevenueLedger {
+		live.ledger = newRevenueLedger()
+	}
+	if live.OnSessionEnd != nil {
+		live.sessions = newSessionTracker()
+	}
+	if live.Archiver != nil && live.Archiver.StartTime == nil {
+		live.Archiver.StartTime = live.archiveStartTime
 	}
 
 	live.wg = sync.WaitGroup{}
 
+	if live.GiftCombo != nil {
+		live.giftCombos = newGiftComboAggregator(live.GiftComboWindow, live.GiftCombo)
+		live.wg.Add(1)
+		go func() {
+			defer live.wg.Done()
+			live.giftCombos.run(ctx)
+		}()
+	}
+
 	for i := 0; i < live.AnalysisRoutineNum; i++ {
 		live.wg.Add(1)
 		go func() {
@@ -80,44 +114,111 @@ func (live *Live) Wait() {
 
 // Join 添加房间
 func (live *Live) Join(roomIDs ...int) error {
+	return live.join(0, roomIDs...)
+}
+
+// 添加房间，attempts为获取服务器的最多尝试次数，0不限制
+// 超过次数时移出该房间并返回错误
+func (live *Live) join(attempts int, roomIDs ...int) error {
 	if len(roomIDs) == 0 {
 		return errors.New("没有要添加的房间")
 	}
 
+	live.roomLock.Lock()
 	for _, roomID := range roomIDs {
 		if _, exist := live.room[roomID]; exist {
+			live.roomLock.Unlock()
 			return fmt.Errorf("房间 %d 已存在", roomID)
 		}
 	}
+	rooms := make([]*liveRoom, 0, len(roomIDs))
+	ctxs := make([]context.Context, 0, len(roomIDs))
 	for _, roomID := range roomIDs {
 		nextCtx, cancel := context.WithCancel(live.ctx)
-
 		room := &liveRoom{
+			live:   live,
 			roomID: roomID,
 			cancel: cancel,
 		}
 		live.room[roomID] = room
-		room.enter()
+		rooms = append(rooms, room)
+		ctxs = append(ctxs, nextCtx)
+	}
+	live.roomLock.Unlock()
+
+	var result error
+	for i, room := range rooms {
+		nextCtx := ctxs[i]
+		if err := room.enter(nextCtx, attempts); err != nil {
+			live.roomLock.Lock()
+			if live.room[room.roomID] == room {
+				delete(live.room, room.roomID)
+			}
+			live.roomLock.Unlock()
+			room.cancel()
+			if result == nil {
+				result = err
+			}
+			continue
+		}
 		go room.heartBeat(nextCtx)
-		live.stormContent[roomID] = make(map[int64]string)
 		go room.receive(nextCtx, live.chSocketMessage)
+		if live.StatusPollInterval > 0 {
+			go live.pollStatus(nextCtx, room)
+		}
 	}
-	return nil
+	return result
 }
 
-// Remove 移出房间
+// Remove 移出房间，同时清除房间的过滤器和进行中的抽奖、投票、排队
 func (live *Live) Remove(roomIDs ...int) error {
 	if len(roomIDs) == 0 {
 		return errors.New("没有要移出的房间")
 	}
+	live.remove(roomIDs, true)
+	return nil
+}
 
+// 移出房间，断开连接并清除运行状态，clearConfig为false时保留SetFilters设置的过滤器和进行中的抽奖、投票、排队
+func (live *Live) remove(roomIDs []int, clearConfig bool) {
 	for _, roomID := range roomIDs {
-		if room, exist := live.room[roomID]; exist {
-			room.cancel()
-			delete(live.room, roomID)
+		live.roomLock.Lock()
+		room, exist := live.room[roomID]
+		delete(live.room, roomID)
+		live.roomLock.Unlock()
+		if !exist {
+			continue
+		}
+		room.cancel()
+		room.closeConn()
+		if clearConfig {
+			live.filters.set(roomID, nil)
+			live.lotteries.clear(roomID)
+			live.polls.clear(roomID)
+			live.queues.clear(roomID)
+		}
+		live.storms.clear(roomID)
+		if live.SpamDetector != nil {
+			live.SpamDetector.clear(roomID)
+		}
+		live.superChats.clear(roomID)
+		live.statuses.clear(roomID)
+		if live.sessions != nil {
+			live.sessions.clear(roomID)
+		}
+		if live.giftCombos != nil {
+			live.giftCombos.clear(roomID)
+		}
+		if live.ledger != nil {
+			live.ledger.clear(roomID)
+		}
+		if live.Archiver != nil {
+			_ = live.Archiver.close(roomID)
+		}
+		if live.Recorder != nil {
+			live.Recorder.stop(roomID)
 		}
 	}
-	return nil
 }
 
 // 拆分数据
@@ -153,14 +254,13 @@ func (live *Live) split(ctx context.Context) {
 			if live.Debug {
 				log.Println(string(payloadBuffer))
 			}
-			live.chOperation <- &operateInfo{RoomID: message.roomID, Operation: head.Operation, Buffer: payloadBuffer}
+			live.chOperation <- &operateInfo{RoomID: message.roomID, Room: message.room, Operation: head.Operation, Buffer: payloadBuffer}
 		}
 	}
 }
 
 // 分析接收到的数据
 func (live *Live) analysis(ctx context.Context) {
-analysis:
 	for {
 		select {
 		case <-ctx.Done():
@@ -171,8 +271,11 @@ analysis:
 		buffer := <-live.chOperation
 		switch buffer.Operation {
 		case WS_OP_HEARTBEAT_REPLY:
+			m := binary.BigEndian.Uint32(buffer.Buffer)
+			if live.sessions != nil {
+				live.sessions.onPopularity(buffer.RoomID, m)
+			}
 			if live.ReceivePopularValue != nil {
-				m := binary.BigEndian.Uint32(buffer.Buffer)
 				live.ReceivePopularValue(buffer.RoomID, m)
 			}
 		case WS_OP_CONNECT_SUCCESS:
@@ -199,141 +302,208 @@ analysis:
 			switch result.CMD {
 			case "LIVE": // 直播开始
 				log.Println(string(buffer.Buffer))
-				if live.Live != nil {
-					live.Live(buffer.RoomID)
-				}
+				live.pushStatus(buffer, result.CMD)
 			case "CLOSE": // 关闭
 				fallthrough
 			case "PREPARING": // 准备
 				fallthrough
+			case "CUT_OFF": // 被切断
+				fallthrough
 			case "END": // 结束
 				log.Println(string(buffer.Buffer))
-				if live.End != nil {
-					live.End(buffer.RoomID)
-				}
+				live.pushStatus(buffer, result.CMD)
 			case "SYS_MSG": // 系统消息
 				if live.SysMessage != nil {
 					m := &SysMsgModel{}
 					_ = json.Unmarshal(buffer.Buffer, m)
+					m.Room = buffer.Room
 					live.SysMessage(buffer.RoomID, m)
 				}
 			case "ROOM_CHANGE": // 房间信息变更
+				live.roomInfos.invalidate(buffer.RoomID)
 				if live.RoomChange != nil {
 					m := &RoomChangeModel{}
 					_ = json.Unmarshal(temp, m)
+					m.Room = buffer.Room
 					live.RoomChange(buffer.RoomID, m)
 				}
 			case "WELCOME": // 用户进入
 				if live.UserEnter != nil {
 					m := &UserEnterModel{}
 					_ = json.Unmarshal(temp, m)
+					m.Room = buffer.Room
 					live.UserEnter(buffer.RoomID, m)
 				}
 			case "WELCOME_GUARD": // 舰长进入
 				if live.GuardEnter != nil {
 					m := &GuardEnterModel{}
 					_ = json.Unmarshal(temp, m)
+					m.Room = buffer.Room
 					live.GuardEnter(buffer.RoomID, m)
 				}
 			case "DANMU_MSG": // 弹幕
-				if live.ReceiveMsg != nil {
-					msgContent := result.Info[1].(string)
-
-					if live.StormFilter && live.storming[buffer.RoomID] {
-						for _, value := range live.stormContent[buffer.RoomID] {
-							if msgContent == value {
-								//log.Println("过滤弹幕：", value)
-								continue analysis
-							}
-						}
-					}
-
-					userInfo := result.Info[2].([]interface{})
-					medalInfo := result.Info[3].([]interface{})
-					m := &MsgModel{
-						UserID:    int64(userInfo[0].(float64)),
-						UserName:  userInfo[1].(string),
-						UserLevel: int(result.Info[4].([]interface{})[0].(float64)),
-						Content:   msgContent,
-						Timestamp: int64(result.Info[9].(map[string]interface{})["ts"].(float64)),
-					}
-					if len(medalInfo) >= 4 {
-						m.MedalLevel = int(medalInfo[0].(float64))
-						m.MedalName = medalInfo[1].(string)
-						m.MedalUpName = medalInfo[2].(string)
-						m.MedalRoomID = int64(medalInfo[3].(float64))
+				m := newMsgModel(result.Info)
+				m.Room = buffer.Room
+				live.storms.observe(buffer.RoomID, m.Content)
+				if live.sessions != nil {
+					live.sessions.onMsg(buffer.RoomID, m)
+				}
+				if live.Normalizer != nil {
+					live.Normalizer.apply(m)
+				}
+				if filtered := live.filters.filter(buffer.RoomID, m); filtered != nil {
+					if live.MsgFiltered != nil {
+						live.MsgFiltered(buffer.RoomID, m, filtered)
 					}
+					continue
+				}
+				if l := live.lotteries.get(buffer.RoomID); l != nil {
+					l.onMsg(m)
+				}
+				if p := live.polls.get(buffer.RoomID); p != nil {
+					p.onMsg(m)
+				}
+				if q := live.queues.get(buffer.RoomID); q != nil {
+					q.onMsg(m)
+				}
+				if live.Commands != nil {
+					live.Commands.Handle(buffer.RoomID, m)
+				}
+				if live.Archiver != nil {
+					live.Archiver.onMsg(buffer.RoomID, m)
+				}
+				if live.ReceiveMsg != nil {
 					live.ReceiveMsg(buffer.RoomID, m)
 				}
 			case "SEND_GIFT": // 礼物通知
+				m := &GiftModel{}
+				_ = json.Unmarshal(temp, m)
+				m.Room = buffer.Room
+				if live.giftCombos != nil {
+					live.giftCombos.addGift(buffer.RoomID, m)
+				}
+				if live.ledger != nil {
+					live.ledger.addGift(buffer.RoomID, m)
+				}
+				if live.sessions != nil {
+					live.sessions.onGift(buffer.RoomID, m)
+				}
+				if live.Archiver != nil {
+					live.Archiver.onGift(buffer.RoomID, m)
+				}
+				if l := live.lotteries.get(buffer.RoomID); l != nil {
+					l.onGift(m)
+				}
+				if p := live.polls.get(buffer.RoomID); p != nil {
+					p.onGift(m)
+				}
 				if live.ReceiveGift != nil {
-					m := &GiftModel{}
-					_ = json.Unmarshal(temp, m)
 					live.ReceiveGift(buffer.RoomID, m)
 				}
 			case "COMBO_SEND": // 连击
+				m := &ComboSendModel{}
+				_ = json.Unmarshal(temp, m)
+				m.Room = buffer.Room
+				if live.giftCombos != nil {
+					live.giftCombos.comboSend(buffer.RoomID, m)
+				}
 				if live.GiftComboSend != nil {
-					m := &ComboSendModel{}
-					_ = json.Unmarshal(temp, m)
 					live.GiftComboSend(buffer.RoomID, m)
 				}
 			case "COMBO_END": // 连击结束
+				m := &ComboEndModel{}
+				_ = json.Unmarshal(temp, m)
+				m.Room = buffer.Room
+				if live.giftCombos != nil {
+					live.giftCombos.comboEnd(buffer.RoomID, m)
+				}
 				if live.GiftComboEnd != nil {
-					m := &ComboEndModel{}
-					_ = json.Unmarshal(temp, m)
 					live.GiftComboEnd(buffer.RoomID, m)
 				}
 			case "GUARD_BUY": // 上船
+				m := &GuardBuyModel{}
+				_ = json.Unmarshal(temp, m)
+				m.Room = buffer.Room
+				if live.ledger != nil {
+					live.ledger.addGuard(buffer.RoomID, m)
+				}
+				if live.sessions != nil {
+					live.sessions.onGuard(buffer.RoomID, m)
+				}
+				if live.Archiver != nil {
+					live.Archiver.onGuard(buffer.RoomID, m)
+				}
+				if l := live.lotteries.get(buffer.RoomID); l != nil {
+					l.onGuard(m)
+				}
+				if q := live.queues.get(buffer.RoomID); q != nil {
+					q.onGuard(m)
+				}
 				if live.GuardBuy != nil {
-					m := &GuardBuyModel{}
-					_ = json.Unmarshal(temp, m)
 					live.GuardBuy(buffer.RoomID, m)
 				}
 			case "ROOM_REAL_TIME_MESSAGE_UPDATE": // 粉丝数更新
 				if live.FansUpdate != nil {
 					m := &FansUpdateModel{}
 					_ = json.Unmarshal(temp, m)
+					m.Room = buffer.Room
 					live.FansUpdate(buffer.RoomID, m)
 				}
 			case "ROOM_RANK": // 小时榜
 				if live.RoomRank != nil {
 					m := &RankModel{}
 					_ = json.Unmarshal(temp, m)
+					m.Room = buffer.Room
 					live.RoomRank(buffer.RoomID, m)
 				}
 			case "SPECIAL_GIFT": // 特殊礼物
 				m := &SpecialGiftModel{}
 				_ = json.Unmarshal(temp, m)
-				if m.Storm.Action == "start" {
-					m.Storm.ID, _ = strconv.ParseInt(m.Storm.TempID.(string), 10, 64)
-				}
-				if m.Storm.Action == "end" {
-					m.Storm.ID = int64(m.Storm.TempID.(float64))
-				}
-				if live.StormFilter && live.ReceiveMsg != nil {
-					if m.Storm.Action == "start" {
-						live.storming[buffer.RoomID] = true
-						live.stormContent[buffer.RoomID][m.Storm.ID] = m.Storm.Content
-						//log.Println("添加过滤弹幕：", m.Storm.ID, m.Storm.Content)
-					}
-					if m.Storm.Action == "end" {
-						delete(live.stormContent[buffer.RoomID], m.Storm.ID)
-						live.storming[buffer.RoomID] = len(live.stormContent) > 0
-						//log.Println("移除过滤弹幕：", m.Storm.ID, live.storming)
-					}
+				m.Room = buffer.Room
+				switch m.Storm.Action {
+				case "start":
+					live.storms.start(buffer.RoomID, &m.Storm)
+				case "end":
+					live.storms.end(buffer.RoomID, m.Storm.ID)
 				}
 				if live.SpecialGift != nil {
 					live.SpecialGift(buffer.RoomID, m)
 				}
 			case "SUPER_CHAT_MESSAGE": // 醒目留言
+				m := &SuperChatMessageModel{}
+				_ = json.Unmarshal(temp, m)
+				m.Room = buffer.Room
+				live.superChats.add(buffer.RoomID, m)
+				if q := live.queues.get(buffer.RoomID); q != nil {
+					q.onSuperChat(m)
+				}
+				if live.ledger != nil {
+					live.ledger.addSuperChat(buffer.RoomID, m)
+				}
+				if live.sessions != nil {
+					live.sessions.onSuperChat(buffer.RoomID, m)
+				}
+				if live.Archiver != nil {
+					live.Archiver.onSuperChat(buffer.RoomID, m)
+				}
 				if live.SuperChatMessage != nil {
-					m := &SuperChatMessageModel{}
-					_ = json.Unmarshal(temp, m)
 					live.SuperChatMessage(buffer.RoomID, m)
 				}
-			case "SUPER_CHAT_MESSAGE_JPN":
-				if live.Debug {
-					log.Println(string(buffer.Buffer))
+			case "SUPER_CHAT_MESSAGE_JPN": // 醒目留言日文翻译
+				m := &SuperChatMessageModel{}
+				_ = json.Unmarshal(temp, m)
+				m.Room = buffer.Room
+				m = live.superChats.mergeJPN(buffer.RoomID, m)
+				if live.SuperChatMessageJPN != nil {
+					live.SuperChatMessageJPN(buffer.RoomID, m)
+				}
+			case "SUPER_CHAT_MESSAGE_DELETE": // 醒目留言删除
+				m := &SuperChatDeleteModel{}
+				_ = json.Unmarshal(temp, m)
+				m.Room = buffer.Room
+				live.superChats.remove(buffer.RoomID, m.IDs)
+				if live.SuperChatDelete != nil {
+					live.SuperChatDelete(buffer.RoomID, m)
 				}
 			case "SYS_GIFT": // 系统礼物
 				fallthrough
@@ -374,44 +544,115 @@ analysis:
 	}
 }
 
-func (room *liveRoom) findServer() error {
-	resRoom, err := httpSend(fmt.Sprintf(roomInitURL, room.roomID))
-	if err != nil {
-		return err
+// 解析弹幕
+func newMsgModel(info []interface{}) *MsgModel {
+	userInfo := info[2].([]interface{})
+	medalInfo := info[3].([]interface{})
+	m := &MsgModel{
+		UserID:    int64(userInfo[0].(float64)),
+		UserName:  userInfo[1].(string),
+		UserLevel: int(info[4].([]interface{})[0].(float64)),
+		Content:   info[1].(string),
+		Timestamp: int64(info[9].(map[string]interface{})["ts"].(float64)),
+	}
+	if len(userInfo) >= 3 {
+		admin, _ := userInfo[2].(float64)
+		m.IsAdmin = admin == 1
+	}
+	if len(info) >= 8 {
+		guardLevel, _ := info[7].(float64)
+		m.GuardLevel = int(guardLevel)
+	}
+	if len(medalInfo) >= 4 {
+		m.MedalLevel = int(medalInfo[0].(float64))
+		m.MedalName = medalInfo[1].(string)
+		m.MedalUpName = medalInfo[2].(string)
+		m.MedalRoomID = int64(medalInfo[3].(float64))
+	}
+	// info[0]: [0, 模式, 字号, 颜色, 发送时间毫秒, 随机数, 0, 用户hash, ..., {extra}]
+	if danmaku, ok := info[0].([]interface{}); ok && len(danmaku) >= 8 {
+		mode, _ := danmaku[1].(float64)
+		fontSize, _ := danmaku[2].(float64)
+		color, _ := danmaku[3].(float64)
+		sendTime, _ := danmaku[4].(float64)
+		rnd, _ := danmaku[5].(float64)
+		m.Mode = int(mode)
+		m.FontSize = int(fontSize)
+		m.Color = int(color)
+		m.SendTime = int64(sendTime)
+		m.UserHash, _ = danmaku[7].(string)
+		m.ID = strconv.FormatInt(int64(rnd), 10)
+		if len(danmaku) >= 16 {
+			if props, ok := danmaku[15].(map[string]interface{}); ok {
+				extra := &danmakuExtra{}
+				if raw, ok := props["extra"].(string); ok && json.Unmarshal([]byte(raw), extra) == nil && extra.IDStr != "" {
+					m.ID = extra.IDStr
+				}
+			}
+		}
 	}
+	return m
+}
 
-	roomInfo := roomInfoResult{}
-	_ = json.Unmarshal(resRoom, &roomInfo)
-	if roomInfo.Code != 0 {
-		return errors.New("房间不正确")
+func (room *liveRoom) findServer() error {
+	ctx := room.live.ctx
+	roomInfo := &roomInfoData{}
+	if _, err := room.live.apiGet(ctx, roomInitPath, url.Values{"id": {strconv.Itoa(room.roomID)}}, roomInfo); err != nil {
+		var apiErr *APIError
+		if errors.As(err, &apiErr) {
+			return errors.New("房间不正确")
+		}
+		return err
 	}
-	room.realRoomID = roomInfo.Data.RoomID
-	resDanmuConfig, err := httpSend(fmt.Sprintf(roomConfigURL, room.realRoomID))
-	if err != nil {
+	room.realRoomID = roomInfo.RoomID
+	room.setInfo(&Room{
+		RoomID:     room.roomID,
+		RealRoomID: roomInfo.RoomID,
+		ShortID:    roomInfo.ShortID,
+	})
+
+	danmuConfig := &danmuData{}
+	if _, err := room.live.apiGet(ctx, roomConfigPath, url.Values{"room_id": {strconv.Itoa(room.realRoomID)}}, danmuConfig); err != nil {
 		return err
 	}
-
-	danmuConfig := danmuConfigResult{}
-	_ = json.Unmarshal(resDanmuConfig, &danmuConfig)
-	room.server = danmuConfig.Data.Host
-	room.port = danmuConfig.Data.Port
-	room.hostServerList = danmuConfig.Data.HostServerList
-	room.token = danmuConfig.Data.Token
+	room.server = danmuConfig.Host
+	room.port = danmuConfig.Port
+	room.hostServerList = danmuConfig.HostServerList
+	room.token = danmuConfig.Token
 	room.currentServerIndex = 0
 	return nil
 }
 
-func (room *liveRoom) createConnect() {
+// 房间ID记录会被其他协程读取，替换时加锁，记录本身不会被修改
+func (room *liveRoom) setInfo(info *Room) {
+	room.infoLock.Lock()
+	defer room.infoLock.Unlock()
+	room.info = info
+}
+
+// 房间ID记录，还没有获取服务器时为nil
+func (room *liveRoom) getInfo() *Room {
+	room.infoLock.Lock()
+	defer room.infoLock.Unlock()
+	return room.info
+}
+
+// 连接，attempts为获取服务器的最多尝试次数，0不限制，房间移出时返回
+func (room *liveRoom) createConnect(ctx context.Context, attempts int) error {
 	for {
 		if room.hostServerList == nil || len(room.hostServerList) == room.currentServerIndex {
-			for {
+			for failed := 1; ; failed++ {
 				err := room.findServer()
-				if err != nil {
-					log.Println("find server err:", err)
-					time.Sleep(500 * time.Millisecond)
-					continue
+				if err == nil {
+					break
+				}
+				log.Println("find server err:", err)
+				if attempts > 0 && failed >= attempts {
+					return err
+				}
+				if err := sleepContext(ctx, 500*time.Millisecond); err != nil {
+					return err
 				}
-				break
 			}
 		}
 
@@ -425,20 +666,56 @@ func (room *liveRoom) createConnect() {
 					room.currentServerIndex++
 					break
 				}
-				time.Sleep(1 * time.Second)
+				if err := sleepContext(ctx, time.Second); err != nil {
+					return err
+				}
 				counter++
 				continue
 			}
-			room.conn = conn
+			if err := room.setConn(ctx, conn); err != nil {
+				return err
+			}
 			log.Println("连接创建成功：", room.hostServerList[room.currentServerIndex].Host, room.hostServerList[room.currentServerIndex].Port)
 			room.currentServerIndex++
-			return
+			return nil
 		}
 	}
 }
 
-func (room *liveRoom) enter() {
-	room.createConnect()
+// 替换连接，关闭之前的连接，房间已经移出时关闭新连接
+func (room *liveRoom) setConn(ctx context.Context, conn *net.TCPConn) error {
+	room.connLock.Lock()
+	defer room.connLock.Unlock()
+	if err := ctx.Err(); err != nil {
+		_ = conn.Close()
+		return err
+	}
+	if room.conn != nil {
+		_ = room.conn.Close()
+	}
+	room.conn = conn
+	return nil
+}
+
+func (room *liveRoom) getConn() *net.TCPConn {
+	room.connLock.Lock()
+	defer room.connLock.Unlock()
+	return room.conn
+}
+
+// 关闭连接，阻塞在读取上的receive协程会返回
+func (room *liveRoom) closeConn() {
+	room.connLock.Lock()
+	defer room.connLock.Unlock()
+	if room.conn != nil {
+		_ = room.conn.Close()
+	}
+}
+
+func (room *liveRoom) enter(ctx context.Context, attempts int) error {
+	if err := room.createConnect(ctx, attempts); err != nil {
+		return err
+	}
 
 	enterInfo := &enterInfo{
 		RoomID:    room.realRoomID,
@@ -455,6 +732,7 @@ func (room *liveRoom) enter() {
 		log.Panic(err)
 	}
 	room.sendData(WS_OP_USER_AUTHENTICATION, payload)
+	return nil
 }
 
 // 心跳
@@ -487,13 +765,11 @@ func (room *liveRoom) receive(ctx context.Context, chSocketMessage chan<- *socke
 		default:
 		}
 
-		_, err := io.ReadFull(room.conn, headerBuffer)
+		_, err := io.ReadFull(room.getConn(), headerBuffer)
 		if err != nil {
-			if counter >= 10 {
-				log.Panic(err)
+			if !room.reconnect(ctx, err, counter) {
+				return
 			}
-			log.Println("read err:", err)
-			room.enter()
 			counter++
 			continue
 		}
@@ -508,19 +784,19 @@ func (room *liveRoom) receive(ctx context.Context, chSocketMessage chan<- *socke
 				log.Println("数据包长度:", head.Length)
 				log.Panic("数据包长度不正确")
 			}
-			room.enter()
+			if !room.reconnect(ctx, nil, counter) {
+				return
+			}
 			counter++
 			continue
 		}
 
 		payloadBuffer := make([]byte, head.Length-WS_PACKAGE_HEADER_TOTAL_LENGTH)
-		_, err = io.ReadFull(room.conn, payloadBuffer)
+		_, err = io.ReadFull(room.getConn(), payloadBuffer)
 		if err != nil {
-			if counter >= 10 {
-				log.Panic(err)
+			if !room.reconnect(ctx, err, counter) {
+				return
 			}
-			log.Println("read err:", err)
-			room.enter()
 			counter++
 			continue
 		}
@@ -529,12 +805,27 @@ func (room *liveRoom) receive(ctx context.Context, chSocketMessage chan<- *socke
 
 		chSocketMessage <- &socketMessage{
 			roomID: room.roomID,
+			room:   room.getInfo(),
 			body:   messageBody,
 		}
 		counter = 0
 	}
 }
 
+// 读取失败后重连，房间已经移出时返回false
+func (room *liveRoom) reconnect(ctx context.Context, err error, counter int) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if err != nil {
+		if counter >= 10 {
+			log.Panic(err)
+		}
+		log.Println("read err:", err)
+	}
+	return room.enter(ctx, 0) == nil
+}
+
 // 发送数据
 func (room *liveRoom) sendData(operation int32, payload []byte) {
 
@@ -556,7 +847,11 @@ func (room *liveRoom) sendData(operation int32, payload []byte) {
 		log.Println(err)
 	}
 
-	_, err = room.conn.Write(b.Bytes())
+	conn := room.getConn()
+	if conn == nil {
+		return
+	}
+	_, err = conn.Write(b.Bytes())
 	if err != nil {
 		log.Println(err)
 	}
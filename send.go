@@ -0,0 +1,153 @@
+package bililive
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const sendMsgPath = "/msg/send"
+
+// 弹幕发送默认参数
+const (
+	defaultSendMaxLength  = 20
+	defaultSendInterval   = time.Second
+	defaultSendMaxRetries = 3
+	defaultSendBackoff    = 2 * time.Second
+)
+
+// 弹幕模式
+const (
+	DanmakuModeScroll = 1 // 滚动
+	DanmakuModeBottom = 4 // 底部
+	DanmakuModeTop    = 5 // 顶部
+)
+
+// ErrEmptyMessage 弹幕内容为空
+var ErrEmptyMessage = errors.New("弹幕内容为空")
+
+// SendOptions 弹幕发送选项
+type SendOptions struct {
+	Color      int           // 颜色，默认白色0xFFFFFF
+	FontSize   int           // 字号，默认25
+	Mode       int           // 弹幕模式，默认滚动
+	MaxLength  int           // 每条弹幕最大字数，默认20，超过时拆分为多条依次发送
+	Interval   time.Duration // 拆分后每条之间的间隔，默认1秒
+	MaxRetries int           // 发送频率过快时的最多重试次数，默认3，小于0不重试
+	Backoff    time.Duration // 首次重试的等待时间，之后每次翻倍，默认2秒
+}
+
+func (opts *SendOptions) withDefaults() SendOptions {
+	o := SendOptions{}
+	if opts != nil {
+		o = *opts
+	}
+	if o.Color == 0 {
+		o.Color = 0xFFFFFF
+	}
+	if o.FontSize <= 0 {
+		o.FontSize = 25
+	}
+	if o.Mode <= 0 {
+		o.Mode = DanmakuModeScroll
+	}
+	if o.MaxLength <= 0 {
+		o.MaxLength = defaultSendMaxLength
+	}
+	if o.Interval <= 0 {
+		o.Interval = defaultSendInterval
+	}
+	if o.MaxRetries == 0 {
+		o.MaxRetries = defaultSendMaxRetries
+	}
+	if o.Backoff <= 0 {
+		o.Backoff = defaultSendBackoff
+	}
+	return o
+}
+
+// SendMessage 使用Credential发送弹幕，opts为nil时使用默认选项
+// 超过长度的内容拆分为多条发送，中途失败时之前的部分已经发出
+// 返回的错误可以使用errors.Is判断，如ErrRateLimited、ErrMessageFiltered、ErrMessageRepeat、ErrMuted
+func (live *Live) SendMessage(ctx context.Context, roomID int, text string, opts *SendOptions) error {
+	if live.Credential == nil {
+		return ErrNoCredential
+	}
+	o := opts.withDefaults()
+	parts := splitMessage(text, o.MaxLength)
+	if len(parts) == 0 {
+		return ErrEmptyMessage
+	}
+	realRoomID, err := live.realRoomID(ctx, roomID)
+	if err != nil {
+		return err
+	}
+	for i, part := range parts {
+		if i > 0 {
+			if err := sleepContext(ctx, o.Interval); err != nil {
+				return err
+			}
+		}
+		if err := live.sendWithRetry(ctx, realRoomID, part, &o); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 发送单条弹幕，频率过快时退避重试
+func (live *Live) sendWithRetry(ctx context.Context, realRoomID int, msg string, o *SendOptions) error {
+	backoff := o.Backoff
+	for attempt := 0; ; attempt++ {
+		err := live.send(ctx, realRoomID, msg, o)
+		if err == nil || !errors.Is(err, ErrRateLimited) || attempt >= o.MaxRetries {
+			return err
+		}
+		if err := sleepContext(ctx, backoff); err != nil {
+			return err
+		}
+		backoff *= 2
+	}
+}
+
+func (live *Live) send(ctx context.Context, realRoomID int, msg string, o *SendOptions) error {
+	form := url.Values{
+		"bubble":   {"0"},
+		"msg":      {msg},
+		"color":    {strconv.Itoa(o.Color)},
+		"mode":     {strconv.Itoa(o.Mode)},
+		"fontsize": {strconv.Itoa(o.FontSize)},
+		"rnd":      {strconv.FormatInt(time.Now().Unix(), 10)},
+		"roomid":   {strconv.Itoa(realRoomID)},
+	}
+	result, err := live.apiPost(ctx, sendMsgPath, form, nil)
+	if err != nil {
+		return err
+	}
+	// 被屏蔽词拦截（f、k）、发送过快（msg in 1s）、重复发送（msg repeat）时code也可能为0，按message归类
+	if apiErr := (&APIError{Code: result.Code, Message: result.Message}); apiErr.Unwrap() != nil {
+		return apiErr
+	}
+	return nil
+}
+
+// 按字数拆分弹幕，去掉首尾空白，换行替换为空格
+func splitMessage(text string, max int) []string {
+	text = strings.TrimSpace(strings.NewReplacer("\r\n", " ", "\n", " ", "\r", " ").Replace(text))
+	runes := []rune(text)
+	var parts []string
+	for len(runes) > 0 {
+		n := max
+		if n > len(runes) {
+			n = len(runes)
+		}
+		if part := strings.TrimSpace(string(runes[:n])); part != "" {
+			parts = append(parts, part)
+		}
+		runes = runes[n:]
+	}
+	return parts
+}
@@ -0,0 +1,125 @@
+package bililive
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 按顺序返回预设结果的发送接口
+type sendStub struct {
+	sync.Mutex
+	replies []sendReply
+	msgs    []string
+	times   []time.Time
+}
+
+type sendReply struct {
+	code    int
+	message string
+}
+
+func (s *sendStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.Lock()
+	defer s.Unlock()
+	if r.FormValue("csrf") != "csrf" || r.FormValue("roomid") != "1000" {
+		writeTestAPI(w, -111, "csrf 校验失败", nil)
+		return
+	}
+	s.msgs = append(s.msgs, r.FormValue("msg"))
+	s.times = append(s.times, time.Now())
+	reply := sendReply{}
+	if len(s.replies) > 0 {
+		reply, s.replies = s.replies[0], s.replies[1:]
+	}
+	writeTestAPI(w, reply.code, reply.message, []interface{}{})
+}
+
+func newSendTest(t *testing.T, replies ...sendReply) (*Live, *sendStub) {
+	stub := &sendStub{replies: replies}
+	mux := http.NewServeMux()
+	mux.Handle(sendMsgPath, stub)
+	live, _ := newTestLive(t, mux)
+	return live, stub
+}
+
+var fastSend = &SendOptions{MaxLength: 5, Interval: time.Millisecond, Backoff: 10 * time.Millisecond}
+
+func TestSendMessageSplit(t *testing.T) {
+	live, stub := newSendTest(t)
+	if err := live.SendMessage(context.Background(), 1, "一二三四五六七\n八九十abc", fastSend); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"一二三四五", "六七 八九", "十abc"}
+	if !reflect.DeepEqual(stub.msgs, want) {
+		t.Fatalf("msgs %q", stub.msgs)
+	}
+	if err := live.SendMessage(context.Background(), 1, " \n ", fastSend); err != ErrEmptyMessage {
+		t.Fatal(err)
+	}
+}
+
+func TestSendMessageRateLimited(t *testing.T) {
+	live, stub := newSendTest(t,
+		sendReply{10030, "您发送弹幕的频率过快"},
+		sendReply{0, "msg in 1s"},
+	)
+	if err := live.SendMessage(context.Background(), 1, "hello", fastSend); err != nil {
+		t.Fatal(err)
+	}
+	if len(stub.msgs) != 3 {
+		t.Fatalf("attempts %d", len(stub.msgs))
+	}
+	// 退避时间翻倍
+	if d := stub.times[1].Sub(stub.times[0]); d < 10*time.Millisecond {
+		t.Fatalf("first backoff %v", d)
+	}
+	if d := stub.times[2].Sub(stub.times[1]); d < 20*time.Millisecond {
+		t.Fatalf("second backoff %v", d)
+	}
+
+	// 超过重试次数
+	live, stub = newSendTest(t,
+		sendReply{0, "msg in 1s"}, sendReply{0, "msg in 1s"}, sendReply{0, "msg in 1s"}, sendReply{0, "msg in 1s"},
+	)
+	opts := *fastSend
+	opts.MaxRetries = 2
+	if err := live.SendMessage(context.Background(), 1, "hello", &opts); !errors.Is(err, ErrRateLimited) {
+		t.Fatal(err)
+	}
+	if len(stub.msgs) != 3 {
+		t.Fatalf("attempts %d", len(stub.msgs))
+	}
+}
+
+func TestSendMessageErrors(t *testing.T) {
+	cases := []struct {
+		reply sendReply
+		want  error
+	}{
+		{sendReply{0, "f"}, ErrMessageFiltered},
+		{sendReply{0, "k"}, ErrMessageFiltered},
+		{sendReply{0, "msg repeat"}, ErrMessageRepeat},
+		{sendReply{-101, "账号未登录"}, ErrNotLoggedIn},
+	}
+	for _, c := range cases {
+		live, stub := newSendTest(t, c.reply)
+		err := live.SendMessage(context.Background(), 1, "hello", fastSend)
+		if !errors.Is(err, c.want) {
+			t.Errorf("%v: got %v", c.reply, err)
+		}
+		// 不是频率限制的错误不重试
+		if len(stub.msgs) != 1 {
+			t.Errorf("%v: attempts %d", c.reply, len(stub.msgs))
+		}
+	}
+
+	live := &Live{}
+	if err := live.SendMessage(context.Background(), 1, "hello", nil); err != ErrNoCredential {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,220 @@
+package bililive
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// SessionStats 一场直播的统计
+type SessionStats struct {
+	DanmakuCount   int    `json:"danmaku_count"`    // 弹幕数量（含被过滤的）
+	UniqueChatters int    `json:"unique_chatters"`  // 发言人数
+	PeakPopularity uint32 `json:"peak_popularity"`  // 最高人气值
+	GiftValue      Value  `json:"gift_value"`       // 付费礼物价值
+	GuardValue     Value  `json:"guard_value"`      // 上船价值
+	SuperChatValue Value  `json:"super_chat_value"` // 醒目留言价值
+	TotalValue     Value  `json:"total_value"`      // 付费总价值
+	NewGuards      int    `json:"new_guards"`       // 上船次数，续费也计入
+	GuardMonths    int    `json:"guard_months"`     // 上船总月数
+}
+
+// Session 一场直播
+type Session struct {
+	ID             string       `json:"id"`               // 场次ID，真实房间ID-开播时间戳
+	RoomID         int          `json:"room_id"`          // 房间ID
+	Room           *Room        `json:"room"`             // 房间ID记录
+	Title          string       `json:"title"`            // 开播时的标题
+	AreaID         int          `json:"area_id"`          // 开播时的分区ID
+	AreaName       string       `json:"area_name"`        // 开播时的分区名称
+	ParentAreaName string       `json:"parent_area_name"` // 开播时的父分区名称
+	StartTime      time.Time    `json:"start_time"`       // 开播时间
+	EndTime        time.Time    `json:"end_time"`         // 下播时间，直播中为零值
+	EndReason      string       `json:"end_reason"`       // 下播原因
+	Stats          SessionStats `json:"stats"`            // 统计
+}
+
+// Duration 直播时长，直播中为到现在的时长
+func (s *Session) Duration() time.Duration {
+	if s.EndTime.IsZero() {
+		return time.Since(s.StartTime)
+	}
+	return s.EndTime.Sub(s.StartTime)
+}
+
+// 场次ID，真实房间ID-开播时间戳，录制的文件也使用该ID命名
+func sessionID(roomID int, room *Room, start time.Time) string {
+	if room != nil && room.RealRoomID > 0 {
+		roomID = room.RealRoomID
+	}
+	return fmt.Sprintf("%d-%d", roomID, start.Unix())
+}
+
+// 进行中的直播
+type sessionState struct {
+	session  *Session
+	chatters map[int64]bool
+}
+
+// 场次记录，按房间记录当前直播
+type sessionTracker struct {
+	sync.Mutex
+	rooms map[int]*sessionState
+}
+
+func newSessionTracker() *sessionTracker {
+	return &sessionTracker{
+		rooms: make(map[int]*sessionState),
+	}
+}
+
+// 开始新的直播，丢弃之前未结束的记录
+func (t *sessionTracker) open(roomID int, status *RoomStatus) *Session {
+	session := &Session{
+		ID:        sessionID(roomID, status.Room, status.LiveTime),
+		RoomID:    roomID,
+		Room:      status.Room,
+		StartTime: status.LiveTime,
+	}
+	t.Lock()
+	defer t.Unlock()
+	t.rooms[roomID] = &sessionState{
+		session:  session,
+		chatters: make(map[int64]bool),
+	}
+	return session
+}
+
+// 结束直播，没有进行中的直播时返回nil
+func (t *sessionTracker) close(roomID int, status *RoomStatus) *Session {
+	t.Lock()
+	defer t.Unlock()
+	state, ok := t.rooms[roomID]
+	if !ok {
+		return nil
+	}
+	delete(t.rooms, roomID)
+	state.session.EndTime = status.EndTime
+	state.session.EndReason = status.EndReason
+	return state.session
+}
+
+// 填充开播时的房间信息
+func (t *sessionTracker) setInfo(roomID int, id string, info *RoomInfo) {
+	t.Lock()
+	defer t.Unlock()
+	state, ok := t.rooms[roomID]
+	if !ok || state.session.ID != id {
+		return
+	}
+	state.session.Title = info.Title
+	state.session.AreaID = info.AreaID
+	state.session.AreaName = info.AreaName
+	state.session.ParentAreaName = info.ParentAreaName
+}
+
+func (t *sessionTracker) get(roomID int) *Session {
+	t.Lock()
+	defer t.Unlock()
+	state, ok := t.rooms[roomID]
+	if !ok {
+		return nil
+	}
+	s := *state.session
+	return &s
+}
+
+// 修改进行中的直播，调用时不需要加锁
+func (t *sessionTracker) update(roomID int, f func(*sessionState)) {
+	t.Lock()
+	defer t.Unlock()
+	if state, ok := t.rooms[roomID]; ok {
+		f(state)
+	}
+}
+
+func (t *sessionTracker) onMsg(roomID int, m *MsgModel) {
+	t.update(roomID, func(state *sessionState) {
+		state.session.Stats.DanmakuCount++
+		state.chatters[m.UserID] = true
+		state.session.Stats.UniqueChatters = len(state.chatters)
+	})
+}
+
+func (t *sessionTracker) onPopularity(roomID int, popularity uint32) {
+	t.update(roomID, func(state *sessionState) {
+		if popularity > state.session.Stats.PeakPopularity {
+			state.session.Stats.PeakPopularity = popularity
+		}
+	})
+}
+
+func (t *sessionTracker) onGift(roomID int, m *GiftModel) {
+	value := m.Value()
+	if !value.Paid {
+		return
+	}
+	t.update(roomID, func(state *sessionState) {
+		state.session.Stats.GiftValue = state.session.Stats.GiftValue.Add(value)
+		state.session.Stats.TotalValue = state.session.Stats.TotalValue.Add(value)
+	})
+}
+
+func (t *sessionTracker) onGuard(roomID int, m *GuardBuyModel) {
+	value := m.Value()
+	t.update(roomID, func(state *sessionState) {
+		// num为购买的月数
+		state.session.Stats.NewGuards++
+		state.session.Stats.GuardMonths += m.Num
+		state.session.Stats.GuardValue = state.session.Stats.GuardValue.Add(value)
+		state.session.Stats.TotalValue = state.session.Stats.TotalValue.Add(value)
+	})
+}
+
+func (t *sessionTracker) onSuperChat(roomID int, m *SuperChatMessageModel) {
+	value := m.Value()
+	t.update(roomID, func(state *sessionState) {
+		state.session.Stats.SuperChatValue = state.session.Stats.SuperChatValue.Add(value)
+		state.session.Stats.TotalValue = state.session.Stats.TotalValue.Add(value)
+	})
+}
+
+func (t *sessionTracker) clear(roomID int) {
+	t.Lock()
+	defer t.Unlock()
+	delete(t.rooms, roomID)
+}
+
+// Session 房间当前进行中的直播，没有时返回nil，需要设置OnSessionEnd
+func (live *Live) Session(roomID int) *Session {
+	if live.sessions == nil {
+		return nil
+	}
+	return live.sessions.get(roomID)
+}
+
+// 开播时记录场次，异步获取标题和分区
+func (live *Live) openSession(roomID int, status *RoomStatus) {
+	if live.sessions == nil {
+		return
+	}
+	session := live.sessions.open(roomID, status)
+	go func() {
+		live.InvalidateRoomInfo(roomID)
+		info, err := live.GetRoomInfo(live.ctx, roomID)
+		if err != nil {
+			return
+		}
+		live.sessions.setInfo(roomID, session.ID, info)
+	}()
+}
+
+// 下播时结束场次
+func (live *Live) closeSession(roomID int, status *RoomStatus) {
+	if live.sessions == nil {
+		return
+	}
+	if session := live.sessions.close(roomID, status); session != nil {
+		live.OnSessionEnd(roomID, session)
+	}
+}
@@ -0,0 +1,24 @@
+package bililive
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSessionGuards(t *testing.T) {
+	tracker := newSessionTracker()
+	tracker.open(1, &RoomStatus{LiveTime: time.Now()})
+	guard := &GuardBuyModel{}
+	guard.UserID = 1
+	guard.GuardLevel = 3
+	guard.Num = 3
+	guard.Price = 138000
+	tracker.onGuard(1, guard)
+	guard.Num = 1
+	tracker.onGuard(1, guard)
+
+	stats := tracker.get(1).Stats
+	if stats.NewGuards != 2 || stats.GuardMonths != 4 {
+		t.Fatalf("guards %d months %d", stats.NewGuards, stats.GuardMonths)
+	}
+}
@@ -0,0 +1,167 @@
+package bililive
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// 刷屏检测默认参数
+const (
+	defaultSpamWindow      = 30 * time.Second
+	defaultSpamSimilarity  = 0.6
+	defaultSpamMinUsers    = 3
+	defaultSpamMinMessages = 5
+	maxSpamClusters        = 200
+)
+
+// SpamWaveModel 刷屏模型
+type SpamWaveModel struct {
+	Text         string    // 代表性内容（第一条弹幕）
+	UserCount    int       // 参与用户数
+	MessageCount int       // 弹幕数量
+	StartTime    time.Time // 第一条弹幕时间
+	LastTime     time.Time // 最后一条弹幕时间
+}
+
+// SpamDetector 刷屏检测，按相似度把时间窗口内的弹幕聚类，参与用户和弹幕数量达到阈值时认为是一波刷屏
+// 相似度使用字符二元组的Jaccard系数，可以识别加了后缀、改了个别字的复制弹幕
+type SpamDetector struct {
+	Window      time.Duration             // 时间窗口，默认30秒
+	Similarity  float64                   // 相似度阈值（0-1），默认0.6
+	MinUsers    int                       // 最少参与用户数，默认3
+	MinMessages int                       // 最少弹幕数量，默认5
+	Drop        bool                      // 是否丢弃刷屏弹幕，检测到刷屏之后的相似弹幕都会被丢弃
+	OnWave      func(int, *SpamWaveModel) // 检测到刷屏通知，每波刷屏只通知一次，为nil时使用Live.SpamWave
+
+	sync.Mutex
+	rooms map[int][]*spamCluster
+}
+
+type spamCluster struct {
+	wave     SpamWaveModel
+	shingles map[string]bool
+	users    map[int64]bool
+	notified bool
+}
+
+// Filter 实现Filter接口
+func (d *SpamDetector) Filter(roomID int, m *MsgModel) (string, bool) {
+	wave, notify, drop := d.observe(roomID, m.Text(), m.UserID, time.Now())
+	if notify && d.OnWave != nil {
+		d.OnWave(roomID, wave)
+	}
+	if drop {
+		return fmt.Sprintf("刷屏弹幕：%s", wave.Text), true
+	}
+	return "", false
+}
+
+// 记录弹幕，返回所属的刷屏、是否刚达到阈值需要通知以及是否丢弃
+func (d *SpamDetector) observe(roomID int, text string, userID int64, now time.Time) (*SpamWaveModel, bool, bool) {
+	d.Lock()
+	defer d.Unlock()
+	if d.rooms == nil {
+		d.rooms = make(map[int][]*spamCluster)
+	}
+
+	window := d.Window
+	if window <= 0 {
+		window = defaultSpamWindow
+	}
+	similarity := d.Similarity
+	if similarity <= 0 {
+		similarity = defaultSpamSimilarity
+	}
+	minUsers := d.MinUsers
+	if minUsers <= 0 {
+		minUsers = defaultSpamMinUsers
+	}
+	minMessages := d.MinMessages
+	if minMessages <= 0 {
+		minMessages = defaultSpamMinMessages
+	}
+
+	// 清理过期的聚类
+	clusters := d.rooms[roomID][:0]
+	for _, c := range d.rooms[roomID] {
+		if now.Sub(c.wave.LastTime) <= window {
+			clusters = append(clusters, c)
+		}
+	}
+
+	shingles := textShingles(text)
+	var best *spamCluster
+	bestScore := 0.0
+	for _, c := range clusters {
+		if score := jaccard(shingles, c.shingles); score >= similarity && score > bestScore {
+			best, bestScore = c, score
+		}
+	}
+	if best == nil {
+		best = &spamCluster{
+			wave: SpamWaveModel{
+				Text:      text,
+				StartTime: now,
+			},
+			shingles: shingles,
+			users:    make(map[int64]bool),
+		}
+		if len(clusters) >= maxSpamClusters {
+			clusters = clusters[1:]
+		}
+		clusters = append(clusters, best)
+	}
+	d.rooms[roomID] = clusters
+
+	best.users[userID] = true
+	best.wave.UserCount = len(best.users)
+	best.wave.MessageCount++
+	best.wave.LastTime = now
+
+	if best.wave.UserCount < minUsers || best.wave.MessageCount < minMessages {
+		return nil, false, false
+	}
+	wave := best.wave
+	notify := !best.notified
+	best.notified = true
+	return &wave, notify, d.Drop
+}
+
+// 清空房间
+func (d *SpamDetector) clear(roomID int) {
+	d.Lock()
+	defer d.Unlock()
+	delete(d.rooms, roomID)
+}
+
+// 字符二元组
+func textShingles(text string) map[string]bool {
+	runes := []rune(text)
+	shingles := make(map[string]bool, len(runes))
+	if len(runes) < 2 {
+		shingles[text] = true
+		return shingles
+	}
+	for i := 0; i+1 < len(runes); i++ {
+		shingles[string(runes[i:i+2])] = true
+	}
+	return shingles
+}
+
+// Jaccard系数
+func jaccard(a, b map[string]bool) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 1
+	}
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	intersection := 0
+	for k := range a {
+		if b[k] {
+			intersection++
+		}
+	}
+	return float64(intersection) / float64(len(a)+len(b)-intersection)
+}
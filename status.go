@@ -0,0 +1,286 @@
+package bililive
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 下播原因
+const (
+	EndReasonNormal = "end"     // 正常下播
+	EndReasonCutOff = "cut_off" // 被切断
+	EndReasonRound  = "round"   // 下播后进入轮播
+)
+
+// 状态来源
+const (
+	StatusSourcePush = "push" // 推送消息
+	StatusSourcePoll = "poll" // 轮询接口
+)
+
+// RoomStatus 直播状态
+type RoomStatus struct {
+	RoomID     int       `json:"room_id"`     // 房间ID
+	Room       *Room     `json:"room"`        // 房间ID记录
+	LiveStatus int       `json:"live_status"` // 直播状态
+	LiveTime   time.Time `json:"live_time"`   // 开播时间
+	EndTime    time.Time `json:"end_time"`    // 下播时间，直播中为零值
+	EndReason  string    `json:"end_reason"`  // 下播原因，直播中为空
+	Message    string    `json:"message"`     // 被切断时的原因
+	Source     string    `json:"source"`      // 状态来源
+	UpdateTime time.Time `json:"update_time"` // 状态变化时间
+}
+
+// IsLive 是否正在直播
+func (s *RoomStatus) IsLive() bool {
+	return s.LiveStatus == LiveStatusLive
+}
+
+// 开播消息
+type liveCmdModel struct {
+	LiveTime int64 `json:"live_time"`
+}
+
+// 下播消息
+type preparingCmdModel struct {
+	Round int `json:"round"`
+}
+
+// 切断消息
+type cutOffCmdModel struct {
+	Msg string `json:"msg"`
+}
+
+// 状态变化
+type statusUpdate struct {
+	liveStatus int
+	liveTime   time.Time
+	reason     string
+	message    string
+	source     string
+	interval   time.Duration // 轮询间隔，这段时间内收到过推送时以推送为准
+}
+
+// 直播状态跟踪，合并推送和轮询，重复的状态只通知一次
+// 轮询结果可能是推送之前查询的，只用来补充漏掉的推送
+type statusTracker struct {
+	sync.Mutex
+	rooms  map[int]*RoomStatus
+	pushes map[int]time.Time // 最近一次推送的时间
+	ended  map[int]time.Time // 最近结束的一场直播的开播时间
+}
+
+func newStatusTracker() *statusTracker {
+	return &statusTracker{
+		rooms:  make(map[int]*RoomStatus),
+		pushes: make(map[int]time.Time),
+		ended:  make(map[int]time.Time),
+	}
+}
+
+func (t *statusTracker) get(roomID int) *RoomStatus {
+	t.Lock()
+	defer t.Unlock()
+	status, ok := t.rooms[roomID]
+	if !ok {
+		return nil
+	}
+	s := *status
+	return &s
+}
+
+// 更新状态，状态变化时返回新状态和之前的状态（第一次为nil）
+func (t *statusTracker) update(roomID int, room *Room, u statusUpdate) (*RoomStatus, *RoomStatus) {
+	t.Lock()
+	defer t.Unlock()
+	now := time.Now()
+	previous, known := t.rooms[roomID]
+	switch u.source {
+	case StatusSourcePush:
+		t.pushes[roomID] = now
+	case StatusSourcePoll:
+		if t.stale(roomID, u, now) {
+			return nil, nil
+		}
+	}
+	if known && previous.LiveStatus == u.liveStatus {
+		// 同一场直播轮询到的开播时间更准确
+		if u.liveStatus == LiveStatusLive && !u.liveTime.IsZero() && !u.liveTime.Equal(previous.LiveTime) {
+			previous.LiveTime = u.liveTime
+		}
+		return nil, nil
+	}
+	status := &RoomStatus{
+		RoomID:     roomID,
+		Room:       room,
+		LiveStatus: u.liveStatus,
+		Source:     u.source,
+		UpdateTime: now,
+	}
+	if u.liveStatus == LiveStatusLive {
+		status.LiveTime = u.liveTime
+		if status.LiveTime.IsZero() {
+			status.LiveTime = now
+		}
+	} else {
+		status.EndTime = now
+		status.EndReason = u.reason
+		status.Message = u.message
+		if known {
+			status.LiveTime = previous.LiveTime
+			if previous.IsLive() {
+				t.ended[roomID] = previous.LiveTime
+			}
+		}
+	}
+	if room == nil && known {
+		status.Room = previous.Room
+	}
+	t.rooms[roomID] = status
+	s := *status
+	if !known {
+		return &s, nil
+	}
+	p := *previous
+	return &s, &p
+}
+
+// 轮询结果是否过时：与一个轮询间隔内的推送矛盾，或者是已经结束的那场直播
+func (t *statusTracker) stale(roomID int, u statusUpdate, now time.Time) bool {
+	if previous, ok := t.rooms[roomID]; ok && previous.LiveStatus != u.liveStatus {
+		if pushed, ok := t.pushes[roomID]; ok && now.Sub(pushed) < u.interval {
+			return true
+		}
+	}
+	if u.liveStatus == LiveStatusLive && !u.liveTime.IsZero() {
+		if ended, ok := t.ended[roomID]; ok && !u.liveTime.After(ended) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *statusTracker) clear(roomID int) {
+	t.Lock()
+	defer t.Unlock()
+	delete(t.rooms, roomID)
+	delete(t.pushes, roomID)
+	delete(t.ended, roomID)
+}
+
+// Status 房间当前直播状态，还不知道状态时返回nil
+func (live *Live) Status(roomID int) *RoomStatus {
+	return live.statuses.get(roomID)
+}
+
+// 更新直播状态，状态变化时处理开播、下播
+func (live *Live) setStatus(roomID int, room *Room, u statusUpdate) {
+	status, previous := live.statuses.update(roomID, room, u)
+	if status == nil {
+		return
+	}
+	if live.StatusChange != nil {
+		live.StatusChange(roomID, status)
+	}
+	if status.IsLive() {
+		if live.ledger != nil {
+			live.ledger.open(roomID)
+		}
+		live.openSession(roomID, status)
+		if live.Archiver != nil {
+			_ = live.Archiver.open(roomID, status.Room, status.LiveTime)
+		}
+		if live.Recorder != nil {
+			live.Recorder.start(live, roomID, status)
+		}
+		if live.Live != nil {
+			live.Live(roomID)
+		}
+		return
+	}
+	// 轮询到的第一个状态为未开播时不通知下播
+	if previous == nil && u.source == StatusSourcePoll || previous != nil && !previous.IsLive() {
+		return
+	}
+	if live.ledger != nil {
+		if summary := live.ledger.close(roomID); summary != nil && live.RevenueSummary != nil {
+			live.RevenueSummary(roomID, summary)
+		}
+	}
+	live.closeSession(roomID, status)
+	if live.Archiver != nil {
+		_ = live.Archiver.close(roomID)
+	}
+	if live.Recorder != nil {
+		live.Recorder.stop(roomID)
+	}
+	if live.End != nil {
+		live.End(roomID)
+	}
+}
+
+// 推送的状态消息
+func (live *Live) pushStatus(buffer *operateInfo, cmd string) {
+	u := statusUpdate{source: StatusSourcePush}
+	switch cmd {
+	case "LIVE":
+		m := &liveCmdModel{}
+		_ = json.Unmarshal(buffer.Buffer, m)
+		u.liveStatus = LiveStatusLive
+		if m.LiveTime > 0 {
+			u.liveTime = time.Unix(m.LiveTime, 0)
+		}
+	case "PREPARING":
+		m := &preparingCmdModel{}
+		_ = json.Unmarshal(buffer.Buffer, m)
+		u.liveStatus = LiveStatusPreparing
+		u.reason = EndReasonNormal
+		if m.Round == 1 {
+			u.liveStatus = LiveStatusRound
+			u.reason = EndReasonRound
+		}
+	case "CUT_OFF":
+		m := &cutOffCmdModel{}
+		_ = json.Unmarshal(buffer.Buffer, m)
+		u.liveStatus = LiveStatusPreparing
+		u.reason = EndReasonCutOff
+		u.message = m.Msg
+	default: // END、CLOSE
+		u.liveStatus = LiveStatusPreparing
+		u.reason = EndReasonNormal
+	}
+	live.setStatus(buffer.RoomID, buffer.Room, u)
+}
+
+// 定时轮询直播状态，补充重连期间漏掉的推送
+func (live *Live) pollStatus(ctx context.Context, room *liveRoom) {
+	for {
+		base := &roomInfoData{}
+		_, err := live.apiGet(ctx, roomInitPath, url.Values{"id": {strconv.Itoa(room.roomID)}}, base)
+		if err == nil {
+			u := statusUpdate{liveStatus: base.LiveStatus, source: StatusSourcePoll, interval: live.StatusPollInterval}
+			switch base.LiveStatus {
+			case LiveStatusLive:
+				if base.LiveTime > 0 {
+					u.liveTime = time.Unix(base.LiveTime, 0)
+				}
+			case LiveStatusRound:
+				u.reason = EndReasonRound
+			default:
+				u.reason = EndReasonNormal
+			}
+			live.setStatus(room.roomID, room.getInfo(), u)
+		} else if live.Debug {
+			log.Println("poll status err:", err)
+		}
+
+		if err := sleepContext(ctx, live.StatusPollInterval); err != nil {
+			return
+		}
+	}
+}
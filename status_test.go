@@ -0,0 +1,76 @@
+package bililive
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStatusStalePoll(t *testing.T) {
+	var events []string
+	live := &Live{
+		statuses: newStatusTracker(),
+		Live:     func(int) { events = append(events, "live") },
+		End:      func(int) { events = append(events, "end") },
+	}
+	room := &Room{RoomID: 1000, RealRoomID: 1000}
+	start := time.Now().Add(-time.Hour).Truncate(time.Second)
+	poll := func(status int, liveTime time.Time) {
+		live.setStatus(1000, room, statusUpdate{liveStatus: status, liveTime: liveTime, reason: EndReasonNormal, source: StatusSourcePoll, interval: time.Minute})
+	}
+
+	live.setStatus(1000, room, statusUpdate{liveStatus: LiveStatusLive, liveTime: start, source: StatusSourcePush})
+	live.setStatus(1000, room, statusUpdate{liveStatus: LiveStatusPreparing, reason: EndReasonNormal, source: StatusSourcePush})
+	// 下播推送之前查询的轮询结果
+	poll(LiveStatusLive, start)
+	poll(LiveStatusPreparing, time.Time{})
+	if want := []string{"live", "end"}; !reflect.DeepEqual(events, want) {
+		t.Fatalf("events %v, want %v", events, want)
+	}
+	if status := live.Status(1000); status.IsLive() || !status.LiveTime.Equal(start) || status.Source != StatusSourcePush {
+		t.Fatalf("status %+v", status)
+	}
+
+	// 推送之后超过轮询间隔，已经结束的那场直播仍然不会重新开始
+	live.statuses.pushes[1000] = time.Now().Add(-2 * time.Minute)
+	poll(LiveStatusLive, start)
+	if len(events) != 2 {
+		t.Fatalf("events %v", events)
+	}
+	// 新的一场直播由轮询补充
+	next := time.Now().Truncate(time.Second)
+	poll(LiveStatusLive, next)
+	if want := []string{"live", "end", "live"}; !reflect.DeepEqual(events, want) {
+		t.Fatalf("events %v, want %v", events, want)
+	}
+	if status := live.Status(1000); !status.LiveTime.Equal(next) || status.Source != StatusSourcePoll {
+		t.Fatalf("status %+v", status)
+	}
+
+	// 与刚收到的推送矛盾的轮询结果被丢弃
+	live.setStatus(1000, room, statusUpdate{liveStatus: LiveStatusLive, source: StatusSourcePush})
+	poll(LiveStatusPreparing, time.Time{})
+	if len(events) != 3 {
+		t.Fatalf("events %v", events)
+	}
+}
+
+func TestStatusPollOnly(t *testing.T) {
+	var events []string
+	live := &Live{
+		statuses: newStatusTracker(),
+		Live:     func(int) { events = append(events, "live") },
+		End:      func(int) { events = append(events, "end") },
+	}
+	poll := func(status int, liveTime time.Time) {
+		live.setStatus(1000, nil, statusUpdate{liveStatus: status, liveTime: liveTime, reason: EndReasonNormal, source: StatusSourcePoll, interval: time.Minute})
+	}
+	poll(LiveStatusPreparing, time.Time{})
+	start := time.Now().Add(-time.Minute).Truncate(time.Second)
+	poll(LiveStatusLive, start)
+	poll(LiveStatusLive, start)
+	poll(LiveStatusPreparing, time.Time{})
+	if want := []string{"live", "end"}; !reflect.DeepEqual(events, want) {
+		t.Fatalf("events %v, want %v", events, want)
+	}
+}
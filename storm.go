@@ -0,0 +1,151 @@
+package bililive
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// 没有持续时间的节奏风暴的默认过期时间
+const defaultStormDuration = 90 * time.Second
+
+// StormInfo 进行中的节奏风暴
+type StormInfo struct {
+	ID        int64     // 节奏风暴ID
+	RoomID    int       // 房间ID
+	Content   string    // 内容
+	Num       int       // 数量
+	StartTime time.Time // 开始时间
+	ExpireAt  time.Time // 过期时间，收不到结束通知时按持续时间过期
+	Count     int       // 已收到的风暴弹幕数量
+}
+
+// stormTracker 按房间记录进行中的节奏风暴
+type stormTracker struct {
+	sync.Mutex
+	rooms map[int]map[int64]*StormInfo
+}
+
+func newStormTracker() *stormTracker {
+	return &stormTracker{
+		rooms: make(map[int]map[int64]*StormInfo),
+	}
+}
+
+// 节奏风暴开始
+func (t *stormTracker) start(roomID int, m *SpecialGiftStorm) {
+	t.Lock()
+	defer t.Unlock()
+	storms, ok := t.rooms[roomID]
+	if !ok {
+		storms = make(map[int64]*StormInfo)
+		t.rooms[roomID] = storms
+	}
+	now := time.Now()
+	duration := time.Duration(m.Time) * time.Second
+	if duration <= 0 {
+		duration = defaultStormDuration
+	}
+	storms[m.ID] = &StormInfo{
+		ID:        m.ID,
+		RoomID:    roomID,
+		Content:   m.Content,
+		Num:       m.Num,
+		StartTime: now,
+		ExpireAt:  now.Add(duration),
+	}
+}
+
+// 节奏风暴结束
+func (t *stormTracker) end(roomID int, id int64) {
+	t.Lock()
+	defer t.Unlock()
+	storms, ok := t.rooms[roomID]
+	if !ok {
+		return
+	}
+	delete(storms, id)
+	if len(storms) == 0 {
+		delete(t.rooms, roomID)
+	}
+}
+
+// 清空房间
+func (t *stormTracker) clear(roomID int) {
+	t.Lock()
+	defer t.Unlock()
+	delete(t.rooms, roomID)
+}
+
+// 获取房间进行中的节奏风暴，同时清理过期的风暴，调用时需要加锁
+func (t *stormTracker) active(roomID int, now time.Time) map[int64]*StormInfo {
+	storms, ok := t.rooms[roomID]
+	if !ok {
+		return nil
+	}
+	for id, storm := range storms {
+		if now.After(storm.ExpireAt) {
+			delete(storms, id)
+		}
+	}
+	if len(storms) == 0 {
+		delete(t.rooms, roomID)
+		return nil
+	}
+	return storms
+}
+
+// 记录弹幕，返回是否为节奏风暴弹幕
+func (t *stormTracker) observe(roomID int, content string) bool {
+	t.Lock()
+	defer t.Unlock()
+	matched := false
+	for _, storm := range t.active(roomID, time.Now()) {
+		if storm.Content == content {
+			storm.Count++
+			matched = true
+		}
+	}
+	return matched
+}
+
+// 是否为节奏风暴弹幕
+func (t *stormTracker) contains(roomID int, content string) bool {
+	t.Lock()
+	defer t.Unlock()
+	for _, storm := range t.active(roomID, time.Now()) {
+		if storm.Content == content {
+			return true
+		}
+	}
+	return false
+}
+
+// 房间进行中的节奏风暴列表
+func (t *stormTracker) list(roomID int) []*StormInfo {
+	t.Lock()
+	defer t.Unlock()
+	storms := t.active(roomID, time.Now())
+	result := make([]*StormInfo, 0, len(storms))
+	for _, storm := range storms {
+		s := *storm
+		result = append(result, &s)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].StartTime.Before(result[j].StartTime)
+	})
+	return result
+}
+
+// Storms 获取房间进行中的节奏风暴
+func (live *Live) Storms(roomID int) []*StormInfo {
+	return live.storms.list(roomID)
+}
+
+// 节奏风暴过滤
+func (live *Live) stormFilter(roomID int, m *MsgModel) (string, bool) {
+	if live.storms.contains(roomID, m.Content) {
+		return "节奏风暴弹幕", true
+	}
+	return "", false
+}
@@ -0,0 +1,93 @@
+package bililive
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// 按推送的处理方式更新节奏风暴
+func pushStorm(t *testing.T, live *Live, roomID int, raw string) {
+	t.Helper()
+	m := &SpecialGiftModel{}
+	if err := json.Unmarshal([]byte(raw), m); err != nil {
+		t.Fatal(err)
+	}
+	switch m.Storm.Action {
+	case "start":
+		live.storms.start(roomID, &m.Storm)
+	case "end":
+		live.storms.end(roomID, m.Storm.ID)
+	}
+}
+
+func stormFiltered(live *Live, roomID int, content string) bool {
+	_, drop := live.stormFilter(roomID, &MsgModel{Content: content})
+	return drop
+}
+
+func TestStormEndPerRoom(t *testing.T) {
+	live := &Live{storms: newStormTracker()}
+	pushStorm(t, live, 1, `{"39":{"action":"start","id":100,"content":"前方高能","num":100,"time":90}}`)
+	pushStorm(t, live, 2, `{"39":{"action":"start","id":200,"content":"前方高能","num":100,"time":90}}`)
+	if !stormFiltered(live, 1, "前方高能") || !stormFiltered(live, 2, "前方高能") {
+		t.Fatal("storm not filtered")
+	}
+	if stormFiltered(live, 1, "正常弹幕") {
+		t.Fatal("normal danmaku filtered")
+	}
+
+	// 结束通知的id是字符串，只结束房间1的风暴
+	pushStorm(t, live, 1, `{"39":{"action":"end","id":"100"}}`)
+	if stormFiltered(live, 1, "前方高能") {
+		t.Fatal("room 1 still filtered after end")
+	}
+	if !stormFiltered(live, 2, "前方高能") || len(live.Storms(2)) != 1 {
+		t.Fatal("room 2 storm ended by room 1")
+	}
+	// 其他房间的同一个ID不影响
+	pushStorm(t, live, 2, `{"39":{"action":"end","id":"100"}}`)
+	if !stormFiltered(live, 2, "前方高能") {
+		t.Fatal("room 2 storm ended by another id")
+	}
+	pushStorm(t, live, 2, `{"39":{"action":"end","id":"200"}}`)
+	if stormFiltered(live, 2, "前方高能") || len(live.Storms(2)) != 0 {
+		t.Fatal("room 2 still filtered after end")
+	}
+}
+
+func TestStormExpire(t *testing.T) {
+	live := &Live{storms: newStormTracker()}
+	pushStorm(t, live, 1, `{"39":{"action":"start","id":100,"content":"前方高能","num":100,"time":5}}`)
+	pushStorm(t, live, 1, `{"39":{"action":"start","id":101,"content":"没有时长","num":100}}`)
+	storms := live.Storms(1)
+	if len(storms) != 2 {
+		t.Fatalf("%d storms", len(storms))
+	}
+	durations := map[int64]time.Duration{100: 5 * time.Second, 101: defaultStormDuration}
+	for _, storm := range storms {
+		if d := storm.ExpireAt.Sub(storm.StartTime); d != durations[storm.ID] {
+			t.Fatalf("storm %d expires after %v", storm.ID, d)
+		}
+	}
+
+	// 收不到结束通知，超过Storm.Time后不再过滤
+	live.storms.Lock()
+	for _, storm := range live.storms.rooms[1] {
+		storm.StartTime = storm.StartTime.Add(-5 * time.Second)
+		storm.ExpireAt = storm.ExpireAt.Add(-5 * time.Second)
+	}
+	live.storms.Unlock()
+	if stormFiltered(live, 1, "前方高能") {
+		t.Fatal("expired storm still filtered")
+	}
+	if !stormFiltered(live, 1, "没有时长") {
+		t.Fatal("storm without time expired early")
+	}
+	if storms := live.Storms(1); len(storms) != 1 || storms[0].ID != 101 {
+		t.Fatalf("storms %+v", storms)
+	}
+	if live.storms.observe(1, "前方高能") {
+		t.Fatal("expired storm observed")
+	}
+}
@@ -0,0 +1,114 @@
+package bililive
+
+import (
+	"sync"
+	"time"
+)
+
+// superChatCache 醒目留言缓存
+// b站的日文翻译(SUPER_CHAT_MESSAGE_JPN)与原留言分开推送，先后顺序不固定，按房间和ID缓存以便合并
+type superChatCache struct {
+	sync.Mutex
+	items map[int]map[int64]*superChatItem
+}
+
+type superChatItem struct {
+	message    *SuperChatMessageModel // 留言
+	messageJPN string                 // 日文翻译
+	expire     time.Time              // 过期时间
+}
+
+// 未收到原留言时翻译的保留时间
+const superChatPendingTTL = 5 * time.Minute
+
+func newSuperChatCache() *superChatCache {
+	return &superChatCache{
+		items: make(map[int]map[int64]*superChatItem),
+	}
+}
+
+// 获取房间缓存，同时清理过期留言
+func (c *superChatCache) room(roomID int, now time.Time) map[int64]*superChatItem {
+	items, ok := c.items[roomID]
+	if !ok {
+		items = make(map[int64]*superChatItem)
+		c.items[roomID] = items
+	}
+	for id, item := range items {
+		if now.After(item.expire) {
+			delete(items, id)
+		}
+	}
+	return items
+}
+
+// 添加留言，如果翻译已先到达则合并
+func (c *superChatCache) add(roomID int, m *SuperChatMessageModel) {
+	c.Lock()
+	defer c.Unlock()
+	now := time.Now()
+	items := c.room(roomID, now)
+	item, ok := items[m.ID]
+	if !ok {
+		item = &superChatItem{}
+		items[m.ID] = item
+	}
+	if m.MessageJPN == "" {
+		m.MessageJPN = item.messageJPN
+	}
+	item.message = m
+	item.expire = superChatExpire(m, now)
+}
+
+// 合并翻译，返回合并后的留言；原留言尚未到达时直接缓存翻译消息
+func (c *superChatCache) mergeJPN(roomID int, m *SuperChatMessageModel) *SuperChatMessageModel {
+	c.Lock()
+	defer c.Unlock()
+	now := time.Now()
+	items := c.room(roomID, now)
+	item, ok := items[m.ID]
+	if !ok {
+		items[m.ID] = &superChatItem{
+			message:    m,
+			messageJPN: m.MessageJPN,
+			expire:     superChatExpire(m, now),
+		}
+		return m
+	}
+	// 复制一份，避免修改已经交给回调的留言
+	merged := *item.message
+	merged.MessageJPN = m.MessageJPN
+	item.message = &merged
+	item.messageJPN = m.MessageJPN
+	return &merged
+}
+
+// 删除留言
+func (c *superChatCache) remove(roomID int, ids []int64) {
+	c.Lock()
+	defer c.Unlock()
+	items, ok := c.items[roomID]
+	if !ok {
+		return
+	}
+	for _, id := range ids {
+		delete(items, id)
+	}
+}
+
+// 清空房间缓存
+func (c *superChatCache) clear(roomID int) {
+	c.Lock()
+	defer c.Unlock()
+	delete(c.items, roomID)
+}
+
+func superChatExpire(m *SuperChatMessageModel, now time.Time) time.Time {
+	if m.EndTime > 0 {
+		return time.Unix(m.EndTime, 0)
+	}
+	if m.Time > 0 {
+		return now.Add(time.Duration(m.Time) * time.Second)
+	}
+	return now.Add(superChatPendingTTL)
+}
@@ -2,23 +2,15 @@ package bililive
 
 import (
 	"crypto/tls"
-	"io/ioutil"
 	"net/http"
+	"time"
 )
 
-func httpSend(url string) ([]byte, error) {
-	tr := &http.Transport{ //解决x509: certificate signed by unknown authority
+// 默认HTTP客户端
+var defaultHTTPClient = &http.Client{
+	Transport: &http.Transport{ //解决x509: certificate signed by unknown authority
+		Proxy:           http.ProxyFromEnvironment,
 		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-	}
-	client := &http.Client{Transport: tr}
-	resp, err := client.Get(url)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-	return body, nil
+	},
+	Timeout: 10 * time.Second,
 }
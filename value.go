@@ -0,0 +1,89 @@
+package bililive
+
+// 金瓜子与人民币比率，1000金瓜子 = 1元
+const GoldCoinPerYuan = 1000
+
+// 硬币类型
+const (
+	CoinTypeGold   = "gold"   // 金瓜子（付费）
+	CoinTypeSilver = "silver" // 银瓜子（免费）
+)
+
+// Value 统一的价值
+// 礼物价格为金/银瓜子，上船价格为金瓜子，醒目留言价格为元，这里统一换算
+type Value struct {
+	CNY  float64 // 人民币金额（元），免费礼物为0
+	Coin int     // 原始瓜子数，醒目留言按金瓜子换算
+	Paid bool    // 是否付费
+}
+
+// Valuer 可以计算价值的事件
+type Valuer interface {
+	Value() Value
+}
+
+// Add 累加价值，付费与免费混合时只累加付费部分
+func (v Value) Add(o Value) Value {
+	if !v.Paid && !o.Paid {
+		return silverValue(v.Coin + o.Coin)
+	}
+	coin := 0
+	if v.Paid {
+		coin += v.Coin
+	}
+	if o.Paid {
+		coin += o.Coin
+	}
+	return goldValue(coin)
+}
+
+// 金瓜子价值
+func goldValue(coin int) Value {
+	return Value{
+		CNY:  float64(coin) / GoldCoinPerYuan,
+		Coin: coin,
+		Paid: true,
+	}
+}
+
+// 银瓜子价值
+func silverValue(coin int) Value {
+	return Value{
+		Coin: coin,
+	}
+}
+
+// 按硬币类型计算价值
+func coinValue(coinType string, coin int) Value {
+	if coinType == CoinTypeGold {
+		return goldValue(coin)
+	}
+	return silverValue(coin)
+}
+
+// Value 礼物价值，按用户实际支付计算
+func (m *GiftModel) Value() Value {
+	return coinValue(m.CoinType, m.PaidCoin())
+}
+
+// Value 合并后的礼物连击价值
+func (m *GiftComboModel) Value() Value {
+	return coinValue(m.CoinType, m.TotalCoin)
+}
+
+// Value 上船价值，价格为金瓜子
+func (m *GuardBuyModel) Value() Value {
+	return goldValue(m.Price * maxInt(m.Num, 1))
+}
+
+// Value 醒目留言价值，价格为元
+func (m *SuperChatMessageModel) Value() Value {
+	return goldValue(m.Price * GoldCoinPerYuan)
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,278 @@
+package bililive
+
+import (
+	"context"
+	"log"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const getRoomBaseInfoPath = "/xlive/web-room/v1/index/getRoomBaseInfo"
+
+// 关注列表默认参数
+const (
+	defaultWatchInterval  = time.Minute
+	defaultWatchBatchSize = 50
+	defaultWatchGrace     = 5 * time.Minute
+	watchJoinAttempts     = 3 // 开播时获取服务器的最多尝试次数，失败后在下次查询时重试
+)
+
+// 接口中的时间为北京时间
+var beijingTime = time.FixedZone("CST", 8*3600)
+
+// 批量房间信息
+type roomBaseInfoData struct {
+	ByRoomIDs map[string]*roomBaseInfo `json:"by_room_ids"`
+}
+
+type roomBaseInfo struct {
+	RoomID     int    `json:"room_id"`
+	ShortID    int    `json:"short_id"`
+	LiveStatus int    `json:"live_status"`
+	LiveTime   string `json:"live_time"`
+}
+
+// 关注的房间
+type watchedRoom struct {
+	joined   bool      // 是否由关注列表加入
+	joining  bool      // 正在加入
+	endSince time.Time // 轮询到下播的时间
+}
+
+// 关注列表，开播时加入房间，下播超过宽限时间后移出
+type roomWatcher struct {
+	sync.Mutex
+	once  sync.Once
+	rooms map[int]*watchedRoom
+}
+
+func newRoomWatcher() *roomWatcher {
+	return &roomWatcher{
+		rooms: make(map[int]*watchedRoom),
+	}
+}
+
+// Watch 关注房间，定时批量查询直播状态，开播时自动Join，下播超过WatchGrace后自动移出
+// 自动移出时保留SetFilters设置的过滤器和进行中的抽奖、投票、排队，需要在Start之后调用
+func (live *Live) Watch(roomIDs ...int) {
+	w := live.watcher
+	w.Lock()
+	for _, roomID := range roomIDs {
+		if _, ok := w.rooms[roomID]; !ok {
+			w.rooms[roomID] = &watchedRoom{}
+		}
+	}
+	w.Unlock()
+	w.once.Do(func() {
+		live.wg.Add(1)
+		go func() {
+			defer live.wg.Done()
+			live.watch(live.ctx)
+		}()
+	})
+}
+
+// Unwatch 取消关注，由关注列表加入的房间会被移出
+func (live *Live) Unwatch(roomIDs ...int) {
+	w := live.watcher
+	var remove []int
+	w.Lock()
+	for _, roomID := range roomIDs {
+		if room, ok := w.rooms[roomID]; ok {
+			if room.joined {
+				remove = append(remove, roomID)
+			}
+			delete(w.rooms, roomID)
+		}
+	}
+	w.Unlock()
+	if len(remove) > 0 {
+		_ = live.Remove(remove...)
+	}
+}
+
+// Watching 关注的房间
+func (live *Live) Watching() []int {
+	w := live.watcher
+	w.Lock()
+	defer w.Unlock()
+	result := make([]int, 0, len(w.rooms))
+	for roomID := range w.rooms {
+		result = append(result, roomID)
+	}
+	sort.Ints(result)
+	return result
+}
+
+// 定时查询关注房间的直播状态
+func (live *Live) watch(ctx context.Context) {
+	interval := live.watchInterval()
+	for {
+		live.checkWatched(ctx)
+		if err := sleepContext(ctx, interval); err != nil {
+			return
+		}
+	}
+}
+
+func (live *Live) watchInterval() time.Duration {
+	if live.WatchInterval <= 0 {
+		return defaultWatchInterval
+	}
+	return live.WatchInterval
+}
+
+func (live *Live) checkWatched(ctx context.Context) {
+	roomIDs := live.Watching()
+	batchSize := live.WatchBatchSize
+	if batchSize <= 0 {
+		batchSize = defaultWatchBatchSize
+	}
+	for start := 0; start < len(roomIDs); start += batchSize {
+		end := start + batchSize
+		if end > len(roomIDs) {
+			end = len(roomIDs)
+		}
+		infos, err := live.getRoomBaseInfo(ctx, roomIDs[start:end])
+		if err != nil {
+			if live.Debug {
+				log.Println("watch err:", err)
+			}
+			continue
+		}
+		for _, roomID := range roomIDs[start:end] {
+			if info, ok := infos[roomID]; ok {
+				live.checkWatchedRoom(roomID, info)
+			}
+		}
+	}
+}
+
+// 根据直播状态加入或移出房间
+func (live *Live) checkWatchedRoom(roomID int, info *roomBaseInfo) {
+	grace := live.WatchGrace
+	if grace <= 0 {
+		grace = defaultWatchGrace
+	}
+	now := time.Now()
+	isLive := info.LiveStatus == LiveStatusLive
+
+	w := live.watcher
+	w.Lock()
+	room, ok := w.rooms[roomID]
+	if !ok || room.joining {
+		w.Unlock()
+		return
+	}
+	join, remove := false, false
+	switch {
+	case isLive:
+		room.endSince = time.Time{}
+		join = !room.joined
+		room.joined = true
+		room.joining = join
+	case room.joined:
+		if room.endSince.IsZero() {
+			room.endSince = now
+		}
+		// 推送的下播时间更早
+		if status := live.Status(roomID); status != nil && !status.IsLive() && status.EndTime.Before(room.endSince) {
+			room.endSince = status.EndTime
+		}
+		if now.Sub(room.endSince) >= grace {
+			room.joined = false
+			room.endSince = time.Time{}
+			remove = true
+		}
+	default:
+		w.Unlock()
+		return
+	}
+	w.Unlock()
+
+	u := watchStatus(info, live.watchInterval())
+	if join {
+		// 获取服务器可能一直失败，不阻塞其他房间的查询
+		live.wg.Add(1)
+		go func() {
+			defer live.wg.Done()
+			live.joinWatched(roomID, room, u)
+		}()
+		return
+	}
+
+	// 用轮询结果补充推送，漏掉的开播、下播消息也能通知到
+	record, _ := live.ResolveRoom(roomID)
+	live.setStatus(roomID, record, u)
+
+	if remove {
+		live.remove([]int{roomID}, false)
+	}
+}
+
+// 加入开播的关注房间，失败时在下次查询时重试
+func (live *Live) joinWatched(roomID int, room *watchedRoom, u statusUpdate) {
+	err := live.join(watchJoinAttempts, roomID)
+
+	w := live.watcher
+	w.Lock()
+	room.joining = false
+	// 已经手动加入的房间不由关注列表管理
+	if err != nil {
+		room.joined = false
+	}
+	watched := w.rooms[roomID] == room
+	w.Unlock()
+	if err != nil {
+		if live.Debug {
+			log.Println("watch join err:", err)
+		}
+		return
+	}
+	// 加入期间取消了关注
+	if !watched {
+		_ = live.Remove(roomID)
+		return
+	}
+	record, _ := live.ResolveRoom(roomID)
+	live.setStatus(roomID, record, u)
+}
+
+// 查询结果转为状态变化
+func watchStatus(info *roomBaseInfo, interval time.Duration) statusUpdate {
+	u := statusUpdate{liveStatus: info.LiveStatus, source: StatusSourcePoll, interval: interval}
+	switch info.LiveStatus {
+	case LiveStatusLive:
+		if t, err := time.ParseInLocation("2006-01-02 15:04:05", info.LiveTime, beijingTime); err == nil && t.Year() > 1 {
+			u.liveTime = t
+		}
+	case LiveStatusRound:
+		u.reason = EndReasonRound
+	default:
+		u.reason = EndReasonNormal
+	}
+	return u
+}
+
+// 批量查询房间信息，返回 请求的房间ID -> 信息
+func (live *Live) getRoomBaseInfo(ctx context.Context, roomIDs []int) (map[int]*roomBaseInfo, error) {
+	query := url.Values{"req_biz": {"link-center"}}
+	for _, roomID := range roomIDs {
+		query.Add("room_ids", strconv.Itoa(roomID))
+	}
+	data := &roomBaseInfoData{}
+	if _, err := live.apiGet(ctx, getRoomBaseInfoPath, query, data); err != nil {
+		return nil, err
+	}
+	result := make(map[int]*roomBaseInfo, len(data.ByRoomIDs))
+	for _, info := range data.ByRoomIDs {
+		result[info.RoomID] = info
+		if info.ShortID > 0 {
+			result[info.ShortID] = info
+		}
+	}
+	return result, nil
+}
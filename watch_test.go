@@ -0,0 +1,186 @@
+package bililive
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// 本地弹幕服务，failRoom的room_init一直返回-412，连接在accepted中依次返回，断开时写入closed
+type danmakuServer struct {
+	failRoom int
+	accepted chan net.Conn
+	closed   chan net.Conn
+}
+
+func newWatchTest(t *testing.T, s *danmakuServer) (*Live, context.CancelFunc) {
+	t.Helper()
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	s.accepted = make(chan net.Conn, 10)
+	s.closed = make(chan net.Conn, 10)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			s.accepted <- conn
+			go func() {
+				_, _ = io.Copy(ioutil.Discard, conn)
+				s.closed <- conn
+			}()
+		}
+	}()
+	port := ln.Addr().(*net.TCPAddr).Port
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(roomInitPath, func(w http.ResponseWriter, r *http.Request) {
+		id, _ := strconv.Atoi(r.URL.Query().Get("id"))
+		if id == s.failRoom {
+			writeTestAPI(w, -412, "请求被拦截", nil)
+			return
+		}
+		writeTestAPI(w, 0, "", &roomInfoData{RoomID: id, LiveStatus: LiveStatusLive})
+	})
+	mux.HandleFunc(roomConfigPath, func(w http.ResponseWriter, r *http.Request) {
+		writeTestAPI(w, 0, "", &danmuData{HostServerList: []*hostServerList{{Host: "127.0.0.1", Port: port}}, Token: "token"})
+	})
+	mux.HandleFunc(getRoomBaseInfoPath, func(w http.ResponseWriter, r *http.Request) {
+		data := &roomBaseInfoData{ByRoomIDs: make(map[string]*roomBaseInfo)}
+		for _, id := range r.URL.Query()["room_ids"] {
+			roomID, _ := strconv.Atoi(id)
+			data.ByRoomIDs[id] = &roomBaseInfo{RoomID: roomID, LiveStatus: LiveStatusLive, LiveTime: "2021-06-01 20:00:00"}
+		}
+		writeTestAPI(w, 0, "", data)
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	live := &Live{
+		APIBaseURL:    srv.URL,
+		HTTPClient:    srv.Client(),
+		WatchInterval: time.Hour,
+	}
+	live.Start(ctx)
+	return live, cancel
+}
+
+func (s *danmakuServer) accept(t *testing.T) net.Conn {
+	t.Helper()
+	select {
+	case conn := <-s.accepted:
+		return conn
+	case <-time.After(5 * time.Second):
+		t.Fatal("no connection")
+	}
+	return nil
+}
+
+func joinedRoom(live *Live, roomID int) bool {
+	live.roomLock.Lock()
+	defer live.roomLock.Unlock()
+	_, ok := live.room[roomID]
+	return ok
+}
+
+func TestWatchJoinFailure(t *testing.T) {
+	server := &danmakuServer{failRoom: 1}
+	live, cancel := newWatchTest(t, server)
+	defer cancel()
+
+	start := time.Now()
+	live.Watch(1, 2)
+	// 房间1获取服务器一直失败，不影响房间2加入
+	server.accept(t)
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("room 2 joined after %v", d)
+	}
+	if status := live.Status(2); status == nil || !status.IsLive() {
+		t.Fatalf("room 2 status %+v", status)
+	}
+
+	// 房间1超过尝试次数后移出，下次查询时重试
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		live.watcher.Lock()
+		room := live.watcher.rooms[1]
+		done := !room.joining && !room.joined
+		live.watcher.Unlock()
+		if done {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("room 1 still joining")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if joinedRoom(live, 1) || live.Status(1) != nil {
+		t.Fatal("room 1 joined")
+	}
+	if !joinedRoom(live, 2) {
+		t.Fatal("room 2 not joined")
+	}
+}
+
+func TestRemoveClosesConnection(t *testing.T) {
+	server := &danmakuServer{}
+	live, cancel := newWatchTest(t, server)
+	defer cancel()
+
+	if err := live.Join(2); err != nil {
+		t.Fatal(err)
+	}
+	conn := server.accept(t)
+	filter := &KeywordFilter{Keywords: []string{"广告"}}
+	live.SetFilters(2, filter)
+
+	// 关注列表移出房间时保留过滤器
+	live.remove([]int{2}, false)
+	select {
+	case closed := <-server.closed:
+		if closed != conn {
+			t.Fatal("wrong connection closed")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("connection not closed")
+	}
+	// 移出后不会重连
+	select {
+	case <-server.accepted:
+		t.Fatal("reconnected after remove")
+	case <-time.After(200 * time.Millisecond):
+	}
+	live.filters.RLock()
+	kept := len(live.filters.rooms[2])
+	live.filters.RUnlock()
+	if kept != 1 {
+		t.Fatal("filters cleared")
+	}
+
+	// 手动移出时清除过滤器
+	if err := live.Join(2); err != nil {
+		t.Fatal(err)
+	}
+	server.accept(t)
+	if err := live.Remove(2); err != nil {
+		t.Fatal(err)
+	}
+	<-server.closed
+	live.filters.RLock()
+	kept = len(live.filters.rooms[2])
+	live.filters.RUnlock()
+	if kept != 0 {
+		t.Fatalf("filters kept: %d", kept)
+	}
+}